@@ -1,7 +1,7 @@
 package clean
 
 import (
-	"github.com/jhandguy/obsidian-vault/internal/vault"
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
 	"github.com/spf13/cobra"
 )
 
@@ -13,27 +13,21 @@ var Cmd = &cobra.Command{
 	SilenceErrors: true,
 }
 
-var remove bool
+var (
+	remove bool
+	dryRun bool
+)
 
 func init() {
 	Cmd.Flags().BoolVar(&remove, "remove", false, "should remove git vault after cleaning")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "should only report what would be removed")
 }
 
 func clean(cmd *cobra.Command, _ []string) error {
-	path, err := cmd.InheritedFlags().GetString("path")
-	if err != nil {
-		return err
-	}
-
-	config, err := cmd.InheritedFlags().GetString("config")
-	if err != nil {
-		return err
-	}
-
-	v, err := vault.New(path, config)
+	v, err := flags.NewVault(cmd)
 	if err != nil {
 		return err
 	}
 
-	return v.Clean(remove)
+	return v.Clean(remove, dryRun)
 }
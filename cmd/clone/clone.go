@@ -1,7 +1,7 @@
 package clone
 
 import (
-	"github.com/jhandguy/obsidian-vault/internal/vault"
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
 	"github.com/spf13/cobra"
 )
 
@@ -20,17 +20,7 @@ func init() {
 }
 
 func clone(cmd *cobra.Command, _ []string) error {
-	path, err := cmd.InheritedFlags().GetString("path")
-	if err != nil {
-		return err
-	}
-
-	config, err := cmd.InheritedFlags().GetString("config")
-	if err != nil {
-		return err
-	}
-
-	v, err := vault.New(path, config)
+	v, err := flags.NewVault(cmd)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/jhandguy/obsidian-vault/internal/password"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "daemon",
+	Short:         "Periodically pull and push vault from and to Git",
+	RunE:          daemon,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	passwordValue string
+	passwordFile  string
+	interval      time.Duration
+	statusFile    string
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&passwordValue, "password", "p", "", "password to encrypt and decrypt the obsidian vault")
+	Cmd.Flags().StringVar(&passwordFile, "password-file", "", "file containing the password to encrypt and decrypt the obsidian vault")
+	Cmd.Flags().DurationVar(&interval, "interval", 15*time.Minute, "interval between syncs")
+	Cmd.Flags().StringVar(&statusFile, "status-file", "", "file to write the sync status to (default inside the git vault)")
+}
+
+func daemon(cmd *cobra.Command, _ []string) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive: %s", interval)
+	}
+
+	pwd, err := password.Resolve(passwordValue, passwordFile)
+	if err != nil {
+		return err
+	}
+
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return v.Daemon(ctx, pwd, interval, statusFile)
+}
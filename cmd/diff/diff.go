@@ -0,0 +1,44 @@
+package diff
+
+import (
+	"os"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "diff [path]",
+	Short:         "Show decrypted changes between local vault and Git",
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          diff,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	password string
+	from     string
+	to       string
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the obsidian vault")
+	Cmd.Flags().StringVar(&from, "from", "HEAD", "commit or date to diff from")
+	Cmd.Flags().StringVar(&to, "to", "", "commit or date to diff to (default local vault)")
+	Cmd.MarkFlagRequired("password")
+}
+
+func diff(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	note := "."
+	if len(args) > 0 {
+		note = args[0]
+	}
+
+	return v.Diff(os.Stdout, note, from, to, password)
+}
@@ -0,0 +1,84 @@
+package flags
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jhandguy/obsidian-vault/internal/obsidian"
+	"github.com/jhandguy/obsidian-vault/internal/vault"
+	"github.com/spf13/cobra"
+)
+
+// OnlyEnvironmentVariable sets the folders of the vault to sync without --only, so that a device only ever syncing
+// some folders needs no flag on every command.
+const OnlyEnvironmentVariable = "OV_ONLY"
+
+// NewVault creates a vault from the persistent flags inherited from the root command.
+func NewVault(cmd *cobra.Command) (*vault.Vault, error) {
+	path, err := cmd.InheritedFlags().GetString("path")
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := cmd.InheritedFlags().GetString("config")
+	if err != nil {
+		return nil, err
+	}
+
+	obsidianExcludes, err := cmd.InheritedFlags().GetBool("obsidian-excludes")
+	if err != nil {
+		return nil, err
+	}
+
+	configProfile, err := cmd.InheritedFlags().GetString("config-profile")
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := obsidian.ParseProfile(configProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	only, err := cmd.InheritedFlags().GetStringSlice("only")
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := os.LookupEnv(OnlyEnvironmentVariable); ok && !cmd.InheritedFlags().Changed("only") {
+		for _, folder := range strings.Split(value, ",") {
+			if folder = strings.TrimSpace(folder); folder != "" {
+				only = append(only, folder)
+			}
+		}
+	}
+
+	symlinks, err := cmd.InheritedFlags().GetString("symlinks")
+	if err != nil {
+		return nil, err
+	}
+
+	symlinkPolicy, err := vault.ParseSymlinkPolicy(symlinks)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := cmd.InheritedFlags().GetInt("backups")
+	if err != nil {
+		return nil, err
+	}
+
+	encryptBackups, err := cmd.InheritedFlags().GetBool("encrypt-backups")
+	if err != nil {
+		return nil, err
+	}
+
+	return vault.New(path, config, vault.Options{
+		ObsidianExcludes: obsidianExcludes,
+		ConfigProfile:    profile,
+		Only:             only,
+		Symlinks:         symlinkPolicy,
+		Backups:          backups,
+		EncryptBackups:   encryptBackups,
+	})
+}
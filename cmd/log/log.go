@@ -0,0 +1,36 @@
+package log
+
+import (
+	"os"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "log <note>",
+	Short:         "List history of a note in Git",
+	Args:          cobra.ExactArgs(1),
+	RunE:          log,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	password string
+	stat     bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the obsidian vault, showing decrypted note sizes instead of encrypted ones")
+	Cmd.Flags().BoolVar(&stat, "stat", false, "should show decrypted diff stats of each commit")
+}
+
+func log(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.Log(os.Stdout, args[0], password, stat)
+}
@@ -0,0 +1,60 @@
+package prune
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/jhandguy/obsidian-vault/internal/retention"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "prune",
+	Short:         "Compact Git history with a retention policy",
+	RunE:          prune,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	keepAll    int
+	keepDaily  int
+	keepWeekly int
+	yes        bool
+	dryRun     bool
+)
+
+func init() {
+	Cmd.Flags().IntVar(&keepAll, "keep-all", 7, "number of days to keep all commits for")
+	Cmd.Flags().IntVar(&keepDaily, "keep-daily", 30, "number of days to keep one commit per day for")
+	Cmd.Flags().IntVar(&keepWeekly, "keep-weekly", 365, "number of days to keep one commit per week for")
+	Cmd.Flags().BoolVarP(&yes, "yes", "y", false, "should force push without confirmation")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "should only report what would be pruned")
+}
+
+func prune(cmd *cobra.Command, _ []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.Prune(retention.New(keepAll, keepDaily, keepWeekly), dryRun, confirm)
+}
+
+func confirm(kept, dropped int) bool {
+	if yes {
+		return true
+	}
+
+	fmt.Printf("Rewrite history keeping %d commits, dropping %d, and force push? [y/N] ", kept, dropped)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -1,7 +1,7 @@
 package pull
 
 import (
-	"github.com/jhandguy/obsidian-vault/internal/vault"
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
 	"github.com/spf13/cobra"
 )
 
@@ -13,28 +13,22 @@ var Cmd = &cobra.Command{
 	SilenceErrors: true,
 }
 
-var password string
+var (
+	password string
+	dryRun   bool
+)
 
 func init() {
 	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the obsidian vault")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "should only report what would be pulled and decrypted")
 	Cmd.MarkFlagRequired("password")
 }
 
 func pull(cmd *cobra.Command, _ []string) error {
-	path, err := cmd.InheritedFlags().GetString("path")
-	if err != nil {
-		return err
-	}
-
-	config, err := cmd.InheritedFlags().GetString("config")
-	if err != nil {
-		return err
-	}
-
-	v, err := vault.New(path, config)
+	v, err := flags.NewVault(cmd)
 	if err != nil {
 		return err
 	}
 
-	return v.Pull(password)
+	return v.Pull(password, dryRun)
 }
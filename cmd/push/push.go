@@ -1,6 +1,7 @@
 package push
 
 import (
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
 	"github.com/jhandguy/obsidian-vault/internal/vault"
 	"github.com/spf13/cobra"
 )
@@ -13,28 +14,33 @@ var Cmd = &cobra.Command{
 	SilenceErrors: true,
 }
 
-var password string
+var (
+	password          string
+	dryRun            bool
+	allowMassDelete   bool
+	maxDeleteCount    int
+	maxDeleteFraction float64
+)
 
 func init() {
 	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to encrypt the obsidian vault")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "should only report what would be encrypted and pushed")
+	Cmd.Flags().BoolVar(&allowMassDelete, "allow-mass-delete", false, "should push regardless of how many files would be removed")
+	Cmd.Flags().IntVar(&maxDeleteCount, "max-delete", vault.DefaultDeleteLimit.Count, "maximum number of files to remove from git vault")
+	Cmd.Flags().Float64Var(&maxDeleteFraction, "max-delete-fraction", vault.DefaultDeleteLimit.Fraction, "maximum fraction of files to remove from git vault")
 	Cmd.MarkFlagRequired("password")
 }
 
 func push(cmd *cobra.Command, _ []string) error {
-	path, err := cmd.InheritedFlags().GetString("path")
+	v, err := flags.NewVault(cmd)
 	if err != nil {
 		return err
 	}
 
-	config, err := cmd.InheritedFlags().GetString("config")
-	if err != nil {
-		return err
-	}
-
-	v, err := vault.New(path, config)
-	if err != nil {
-		return err
+	limit := vault.DeleteLimit{Count: maxDeleteCount, Fraction: maxDeleteFraction}
+	if allowMassDelete {
+		limit = vault.DeleteLimit{}
 	}
 
-	return v.Push(password)
+	return v.Push(password, dryRun, limit)
 }
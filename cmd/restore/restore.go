@@ -0,0 +1,51 @@
+package restore
+
+import (
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "restore [path]",
+	Short:         "Restore and decrypt a note, folder or vault from Git history",
+	Args:          args,
+	RunE:          restore,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	password string
+	at       string
+	output   string
+	all      bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the obsidian vault")
+	Cmd.Flags().StringVar(&at, "at", "HEAD", "commit, snapshot or date to restore from")
+	Cmd.Flags().StringVarP(&output, "output", "o", "", "directory to restore into (default local vault)")
+	Cmd.Flags().BoolVar(&all, "all", false, "should restore the whole vault into the output directory")
+	Cmd.MarkFlagRequired("password")
+}
+
+func args(cmd *cobra.Command, args []string) error {
+	if all {
+		return cobra.NoArgs(cmd, args)
+	}
+
+	return cobra.ExactArgs(1)(cmd, args)
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	if all {
+		return v.RestoreAll(at, output, password)
+	}
+
+	return v.Restore(args[0], at, output, password)
+}
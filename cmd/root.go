@@ -6,8 +6,18 @@ import (
 
 	"github.com/jhandguy/obsidian-vault/cmd/clean"
 	"github.com/jhandguy/obsidian-vault/cmd/clone"
+	"github.com/jhandguy/obsidian-vault/cmd/daemon"
+	"github.com/jhandguy/obsidian-vault/cmd/diff"
+	"github.com/jhandguy/obsidian-vault/cmd/log"
+	"github.com/jhandguy/obsidian-vault/cmd/prune"
 	"github.com/jhandguy/obsidian-vault/cmd/pull"
 	"github.com/jhandguy/obsidian-vault/cmd/push"
+	"github.com/jhandguy/obsidian-vault/cmd/restore"
+	"github.com/jhandguy/obsidian-vault/cmd/snapshot"
+	"github.com/jhandguy/obsidian-vault/cmd/trash"
+	"github.com/jhandguy/obsidian-vault/cmd/undo"
+	"github.com/jhandguy/obsidian-vault/cmd/verify"
+	"github.com/jhandguy/obsidian-vault/cmd/watch"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -29,12 +39,28 @@ func init() {
 
 	cmd.AddCommand(clean.Cmd)
 	cmd.AddCommand(clone.Cmd)
+	cmd.AddCommand(daemon.Cmd)
+	cmd.AddCommand(diff.Cmd)
+	cmd.AddCommand(log.Cmd)
+	cmd.AddCommand(prune.Cmd)
 	cmd.AddCommand(pull.Cmd)
 	cmd.AddCommand(push.Cmd)
+	cmd.AddCommand(restore.Cmd)
+	cmd.AddCommand(snapshot.Cmd)
+	cmd.AddCommand(trash.Cmd)
+	cmd.AddCommand(undo.Cmd)
+	cmd.AddCommand(verify.Cmd)
+	cmd.AddCommand(watch.Cmd)
 
 	cmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug for ov")
 	cmd.PersistentFlags().String("path", ".", "path to the obsidian vault")
 	cmd.PersistentFlags().String("config", ".obsidian", "name of the config folder")
+	cmd.PersistentFlags().String("config-profile", "settings", "parts of the config folder to sync: none, settings or full")
+	cmd.PersistentFlags().StringSlice("only", nil, "folders of the vault to sync, all by default or as set in OV_ONLY")
+	cmd.PersistentFlags().String("symlinks", "store", "how to sync symlinks: skip, store or follow")
+	cmd.PersistentFlags().Int("backups", 5, "number of local vault backups taken before pulling to keep")
+	cmd.PersistentFlags().Bool("encrypt-backups", false, "should encrypt local vault backups with the vault password")
+	cmd.PersistentFlags().Bool("obsidian-excludes", false, "should exclude files matching the excluded files setting of the config folder")
 }
 
 func setup() {
@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"os"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Create, list and restore named snapshots in Git",
+}
+
+var createCmd = &cobra.Command{
+	Use:           "create <name>",
+	Short:         "Create named snapshot of the git vault",
+	Args:          cobra.ExactArgs(1),
+	RunE:          create,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var listCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List named snapshots of the git vault",
+	Args:          cobra.NoArgs,
+	RunE:          list,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:           "restore <name>",
+	Short:         "Restore and decrypt named snapshot into a directory",
+	Args:          cobra.ExactArgs(1),
+	RunE:          restore,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	at       string
+	password string
+	output   string
+)
+
+func init() {
+	Cmd.AddCommand(createCmd)
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(restoreCmd)
+
+	createCmd.Flags().StringVar(&at, "at", "HEAD", "commit, snapshot or date to snapshot")
+
+	restoreCmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the obsidian vault")
+	restoreCmd.Flags().StringVarP(&output, "output", "o", "", "directory to restore into")
+	restoreCmd.MarkFlagRequired("password")
+	restoreCmd.MarkFlagRequired("output")
+}
+
+func create(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.CreateSnapshot(args[0], at)
+}
+
+func list(cmd *cobra.Command, _ []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.ListSnapshots(os.Stdout)
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.RestoreSnapshot(args[0], output, password)
+}
@@ -0,0 +1,88 @@
+package trash
+
+import (
+	"os"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore and empty files removed from local vault",
+}
+
+var listCmd = &cobra.Command{
+	Use:           "list [name]",
+	Short:         "List trashes of the local vault, or files of a trash",
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          list,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:           "restore <name> [path]",
+	Short:         "Restore files of a trash into the local vault",
+	Args:          cobra.RangeArgs(1, 2),
+	RunE:          restore,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var emptyCmd = &cobra.Command{
+	Use:           "empty [name]",
+	Short:         "Permanently remove all trashes, or a trash",
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          empty,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(restoreCmd)
+	Cmd.AddCommand(emptyCmd)
+}
+
+func list(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	return v.ListTrash(os.Stdout, name)
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	path := "."
+	if len(args) > 1 {
+		path = args[1]
+	}
+
+	return v.RestoreTrash(args[0], path)
+}
+
+func empty(cmd *cobra.Command, args []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	return v.EmptyTrash(name)
+}
@@ -0,0 +1,30 @@
+package undo
+
+import (
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "undo",
+	Short:         "Restore local vault as it was before the last pull",
+	Args:          cobra.NoArgs,
+	RunE:          undo,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var password string
+
+func init() {
+	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the local vault backup")
+}
+
+func undo(cmd *cobra.Command, _ []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.Undo(password)
+}
@@ -0,0 +1,37 @@
+package verify
+
+import (
+	"os"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "verify",
+	Short:         "Check that every file of the git vault decrypts",
+	Args:          cobra.NoArgs,
+	RunE:          verify,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	password string
+	history  bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&password, "password", "p", "", "password to decrypt the obsidian vault")
+	Cmd.Flags().BoolVar(&history, "history", false, "should verify every file of the git history")
+	Cmd.MarkFlagRequired("password")
+}
+
+func verify(cmd *cobra.Command, _ []string) error {
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	return v.Verify(os.Stdout, password, history)
+}
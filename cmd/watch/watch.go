@@ -0,0 +1,50 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/cmd/flags"
+	"github.com/jhandguy/obsidian-vault/internal/password"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:           "watch",
+	Short:         "Watch local vault and push changes to Git",
+	RunE:          watch,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	passwordValue string
+	passwordFile  string
+	debounce      time.Duration
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&passwordValue, "password", "p", "", "password to encrypt the obsidian vault")
+	Cmd.Flags().StringVar(&passwordFile, "password-file", "", "file containing the password to encrypt the obsidian vault")
+	Cmd.Flags().DurationVar(&debounce, "debounce", 10*time.Second, "quiet period after the last change before pushing")
+}
+
+func watch(cmd *cobra.Command, _ []string) error {
+	pwd, err := password.Resolve(passwordValue, passwordFile)
+	if err != nil {
+		return err
+	}
+
+	v, err := flags.NewVault(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return v.Watch(ctx, pwd, debounce)
+}
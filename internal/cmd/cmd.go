@@ -14,3 +14,13 @@ func Run(shell, command string, stdout, stderr io.Writer) error {
 	cmd.Stderr = stderr
 	return cmd.Run()
 }
+
+// RunWithInput runs the command like Run, writing stdin to its standard input.
+func RunWithInput(shell, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	zap.S().Debug(command)
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
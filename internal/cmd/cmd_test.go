@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,6 +19,17 @@ func TestRunSuccessfulCommand(t *testing.T) {
 	assert.Empty(t, stderr.String())
 }
 
+func TestRunWithInput(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	err := RunWithInput("sh", "cat", strings.NewReader("foo\n"), stdout, stderr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "foo\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
 func TestRunNonexistentCommand(t *testing.T) {
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
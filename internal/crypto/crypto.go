@@ -1,10 +1,19 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"time"
 
 	"golang.org/x/crypto/scrypt"
 )
@@ -15,57 +24,187 @@ func New() *Crypto {
 	return &Crypto{}
 }
 
-func (c *Crypto) Decrypt(data []byte, password string, fileName string) ([]byte, error) {
-	key, err := c.deriveKey(password, fileName)
+// Metadata holds the file attributes encrypted along with the content, where Mode only holds the executable bits
+// and Symlink tells that the content is the target of a symbolic link.
+type Metadata struct {
+	ModTime time.Time   `json:"mtime,omitzero"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	Symlink bool        `json:"symlink,omitempty"`
+}
+
+var (
+	ErrTruncated      = errors.New("ciphertext is truncated")
+	ErrUnknownFormat  = errors.New("ciphertext has an unknown format")
+	ErrWrongPassword  = errors.New("ciphertext was encrypted under a different password")
+	ErrAuthentication = errors.New("ciphertext failed authentication")
+)
+
+// magic and a version byte prefix ciphertexts carrying metadata, and are authenticated along with them.
+// Ciphertexts without them are plain nonce and sealed content, as written by earlier versions.
+const magic = "OVF"
+
+// version is followed by a key check value and the length of the sealed content, telling a different password
+// and a truncated ciphertext apart from a failed authentication, then by the nonce and sealed content.
+const version byte = 1
+
+const keyCheckSize = 8
+
+var keyCheckLabel = []byte("obsidian-vault key check")
+
+func (c *Crypto) Decrypt(data []byte, password string, fileName string) ([]byte, Metadata, error) {
+	key, err := c.Key(password, fileName)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	block, err := aes.NewCipher(key)
+	return c.Open(data, key)
+}
+
+// Open decrypts data with a key derived by Key, so that a ciphertext just sealed can be checked without deriving it again.
+func (c *Crypto) Open(data, key []byte) ([]byte, Metadata, error) {
+	gcm, err := c.newGCM(key)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	if len(data) > len(magic) && bytes.HasPrefix(data, []byte(magic)) {
+		plaintext, metadata, err := c.open(key, gcm, data)
+		if err == nil {
+			return plaintext, metadata, nil
+		}
+
+		if legacy, legacyErr := c.openLegacy(gcm, data); legacyErr == nil {
+			return legacy, Metadata{}, nil
+		}
+
+		return nil, Metadata{}, err
+	}
+
+	plaintext, err := c.openLegacy(gcm, data)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	nonceSize := gcm.NonceSize()
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	return plaintext, Metadata{}, nil
+}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+func (c *Crypto) Encrypt(plaintext []byte, metadata Metadata, password, fileName string) ([]byte, error) {
+	key, err := c.Key(password, fileName)
 	if err != nil {
 		return nil, err
 	}
 
-	return plaintext, nil
+	return c.Seal(plaintext, metadata, key)
 }
 
-func (c *Crypto) Encrypt(plaintext []byte, password, fileName string) ([]byte, error) {
-	key, err := c.deriveKey(password, fileName)
+// Seal encrypts plaintext and metadata with a key derived by Key.
+func (c *Crypto) Seal(plaintext []byte, metadata Metadata, key []byte) ([]byte, error) {
+	gcm, err := c.newGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
+	encoded, err := json.Marshal(metadata)
 	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	payload := binary.BigEndian.AppendUint32(nil, uint32(len(encoded)))
+	payload = append(payload, encoded...)
+	payload = append(payload, plaintext...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	prefix := append([]byte(magic), version)
+	prefix = append(prefix, c.keyCheck(key)...)
+	prefix = binary.BigEndian.AppendUint64(prefix, uint64(len(payload)+gcm.Overhead()))
+
+	data := append(bytes.Clone(prefix), nonce...)
+	return gcm.Seal(data, nonce, payload, prefix), nil
+}
+
+func (c *Crypto) open(key []byte, gcm cipher.AEAD, data []byte) ([]byte, Metadata, error) {
+	if data[len(magic)] != version {
+		return nil, Metadata{}, ErrUnknownFormat
+	}
+
+	prefixSize := len(magic) + 1 + keyCheckSize + 8
+	if len(data) < prefixSize {
+		return nil, Metadata{}, ErrTruncated
+	}
+
+	if !hmac.Equal(data[len(magic)+1:len(magic)+1+keyCheckSize], c.keyCheck(key)) {
+		return nil, Metadata{}, ErrWrongPassword
+	}
+
+	length := binary.BigEndian.Uint64(data[prefixSize-8 : prefixSize])
+	prefix, data := data[:prefixSize], data[prefixSize:]
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, Metadata{}, ErrTruncated
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	if uint64(len(ciphertext)) < length {
+		return nil, Metadata{}, ErrTruncated
+	}
+
+	payload, err := gcm.Open(nil, nonce, ciphertext, prefix)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, ErrAuthentication
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+	if len(payload) < 4 {
+		return nil, Metadata{}, fmt.Errorf("%w: metadata too short", ErrUnknownFormat)
+	}
+
+	size := binary.BigEndian.Uint32(payload)
+	if uint64(size) > uint64(len(payload)-4) {
+		return nil, Metadata{}, fmt.Errorf("%w: metadata too short", ErrUnknownFormat)
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal(payload[4:4+size], &metadata); err != nil {
+		return nil, Metadata{}, fmt.Errorf("%w: failed to decode metadata: %v", ErrUnknownFormat, err)
+	}
+
+	return payload[4+size:], metadata, nil
+}
+
+func (c *Crypto) openLegacy(gcm cipher.AEAD, data []byte) ([]byte, error) {
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize+gcm.Overhead() {
+		return nil, ErrTruncated
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return plaintext, nil
+}
+
+func (c *Crypto) keyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(keyCheckLabel)
+	return mac.Sum(nil)[:keyCheckSize]
+}
+
+func (c *Crypto) newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
 		return nil, err
 	}
 
-	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+	return cipher.NewGCM(block)
 }
 
-func (c *Crypto) deriveKey(password, fileName string) ([]byte, error) {
+// Key derives the key of a file from the password, salted with the file name.
+func (c *Crypto) Key(password, fileName string) ([]byte, error) {
 	return scrypt.Key([]byte(password), []byte(fileName), 32768, 8, 1, 32)
 }
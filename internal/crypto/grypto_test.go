@@ -1,7 +1,11 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto/rand"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,22 +16,122 @@ func TestEncryptionAndDecryptionPreserveOriginalData(t *testing.T) {
 	password := "consectetur-adipiscing-elit"
 	fileName := "LoremIpsum.md"
 
-	data, err := c.Encrypt(plaintext, password, fileName)
+	data, err := c.Encrypt(plaintext, Metadata{}, password, fileName)
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, data)
 	assert.NotEqual(t, plaintext, data)
 
-	decrypted, err := c.Decrypt(data, password, fileName)
+	decrypted, _, err := c.Decrypt(data, password, fileName)
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, decrypted)
 	assert.Equal(t, plaintext, decrypted)
 
-	encrypted, err := c.Encrypt(plaintext, password, fileName)
+	encrypted, err := c.Encrypt(plaintext, Metadata{}, password, fileName)
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, encrypted)
 	assert.NotEqual(t, plaintext, encrypted)
 	assert.NotEqual(t, data, encrypted)
 }
+
+func TestSealAndOpenWithDerivedKey(t *testing.T) {
+	c := New()
+	plaintext := []byte("Lorem ipsum dolor sit amet")
+	password := "consectetur-adipiscing-elit"
+	fileName := "LoremIpsum.md"
+
+	key, err := c.Key(password, fileName)
+	assert.NoError(t, err)
+
+	data, err := c.Seal(plaintext, Metadata{}, key)
+	assert.NoError(t, err)
+
+	decrypted, _, err := c.Open(data, key)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	decrypted, _, err = c.Decrypt(data, password, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	other, err := c.Key(password, "DolorSitAmet.md")
+	assert.NoError(t, err)
+
+	_, _, err = c.Open(data, other)
+	assert.ErrorIs(t, err, ErrWrongPassword)
+}
+
+func TestEncryptionPreservesMetadata(t *testing.T) {
+	c := New()
+	plaintext := []byte("Lorem ipsum dolor sit amet")
+	password := "consectetur-adipiscing-elit"
+	fileName := "LoremIpsum.md"
+	metadata := Metadata{ModTime: time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC), Mode: 0755}
+
+	data, err := c.Encrypt(plaintext, metadata, password, fileName)
+	assert.NoError(t, err)
+
+	decrypted, decryptedMetadata, err := c.Decrypt(data, password, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+	assert.True(t, metadata.ModTime.Equal(decryptedMetadata.ModTime))
+	assert.Equal(t, metadata.Mode, decryptedMetadata.Mode)
+
+	data[len(magic)] ^= 1
+	_, _, err = c.Decrypt(data, password, fileName)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, _, err = c.Decrypt(append([]byte(magic), version), password, fileName)
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+func TestDecryptionOfLegacyFormat(t *testing.T) {
+	c := New()
+	plaintext := []byte("Lorem ipsum dolor sit amet")
+	password := "consectetur-adipiscing-elit"
+	fileName := "LoremIpsum.md"
+
+	key, err := c.Key(password, fileName)
+	assert.NoError(t, err)
+
+	gcm, err := c.newGCM(key)
+	assert.NoError(t, err)
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	assert.NoError(t, err)
+
+	decrypted, metadata, err := c.Decrypt(gcm.Seal(nonce, nonce, plaintext, nil), password, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+	assert.Equal(t, Metadata{}, metadata)
+
+	_, _, err = c.Decrypt([]byte("short"), password, fileName)
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+func TestDecryptionErrors(t *testing.T) {
+	c := New()
+	plaintext := []byte("Lorem ipsum dolor sit amet")
+	password := "consectetur-adipiscing-elit"
+	fileName := "LoremIpsum.md"
+
+	data, err := c.Encrypt(plaintext, Metadata{}, password, fileName)
+	assert.NoError(t, err)
+
+	_, _, err = c.Decrypt(data, "sed-do-eiusmod", fileName)
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	_, _, err = c.Decrypt(data[:len(data)-1], password, fileName)
+	assert.ErrorIs(t, err, ErrTruncated)
+
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	_, _, err = c.Decrypt(tampered, password, fileName)
+	assert.ErrorIs(t, err, ErrAuthentication)
+
+	_, _, err = c.Decrypt(append([]byte(magic+"\x09"), data[len(magic)+1:]...), password, fileName)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,193 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// streamMagic and a version byte prefix ciphertexts encrypted as a stream of chunks, such as backups,
+// followed by a key check value and the nonce prefix of the chunks. The prefix is authenticated along with every chunk.
+const streamMagic = "OVS"
+
+const (
+	streamChunkSize   = 64 * 1024
+	streamNoncePrefix = 7
+)
+
+type streamWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte
+}
+
+// NewWriter returns a writer encrypting everything written to it into w in chunks, with a key derived by Key.
+// Close must be called to write the last chunk, which tells a complete stream apart from a truncated one.
+func (c *Crypto) NewWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	gcm, err := c.newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce[:streamNoncePrefix]); err != nil {
+		return nil, err
+	}
+
+	prefix := append([]byte(streamMagic), version)
+	prefix = append(prefix, c.keyCheck(key)...)
+	prefix = append(prefix, nonce[:streamNoncePrefix]...)
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{w: w, gcm: gcm, prefix: prefix, nonce: nonce}, nil
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for len(s.buf) > streamChunkSize {
+		if err := s.seal(s.buf[:streamChunkSize], false); err != nil {
+			return 0, err
+		}
+		s.buf = s.buf[streamChunkSize:]
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last chunk, without closing the underlying writer.
+func (s *streamWriter) Close() error {
+	return s.seal(s.buf, true)
+}
+
+func (s *streamWriter) seal(chunk []byte, last bool) error {
+	if s.counter == math.MaxUint32 {
+		return errors.New("stream is too long")
+	}
+
+	sealed := s.gcm.Seal(nil, chunkNonce(s.nonce, s.counter, last), chunk, s.prefix)
+	s.counter++
+
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	nonce   []byte
+	counter uint32
+	chunk   []byte
+	buf     []byte
+	done    bool
+}
+
+// NewReader returns a reader decrypting a stream written by NewWriter from r, with the key it was encrypted with.
+// Reading fails with ErrTruncated when the stream ends before its last chunk.
+func (c *Crypto) NewReader(r io.Reader, key []byte) (io.Reader, error) {
+	gcm, err := c.newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, len(streamMagic)+1+keyCheckSize+streamNoncePrefix)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(prefix, []byte(streamMagic)) || prefix[len(streamMagic)] != version {
+		return nil, ErrUnknownFormat
+	}
+
+	check := prefix[len(streamMagic)+1 : len(streamMagic)+1+keyCheckSize]
+	if !hmac.Equal(check, c.keyCheck(key)) {
+		return nil, ErrWrongPassword
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	copy(nonce, prefix[len(prefix)-streamNoncePrefix:])
+
+	return &streamReader{
+		r:      bufio.NewReader(r),
+		gcm:    gcm,
+		prefix: prefix,
+		nonce:  nonce,
+		chunk:  make([]byte, streamChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+
+		if err := s.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *streamReader) open() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	last := err != nil
+	if !last {
+		if _, err := s.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+
+	if n < s.gcm.Overhead() {
+		return ErrTruncated
+	}
+
+	plaintext, err := s.gcm.Open(s.buf[:0], chunkNonce(s.nonce, s.counter, last), s.chunk[:n], s.prefix)
+	if err != nil {
+		// a chunk sealed as any but the last one means the stream was cut right after it
+		if _, openErr := s.gcm.Open(nil, chunkNonce(s.nonce, s.counter, !last), s.chunk[:n], s.prefix); openErr == nil && last {
+			return ErrTruncated
+		}
+
+		return ErrAuthentication
+	}
+
+	s.counter++
+	s.buf = plaintext
+	s.done = last
+	return nil
+}
+
+// chunkNonce returns the nonce of a chunk, made of the nonce prefix of the stream, the index of the chunk
+// and whether it is the last one, so that chunks can neither be reordered nor dropped from the end.
+func chunkNonce(nonce []byte, counter uint32, last bool) []byte {
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefix:], counter)
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encryptStream(t *testing.T, c *Crypto, key, plaintext []byte) []byte {
+	var buf bytes.Buffer
+	w, err := c.NewWriter(&buf, key)
+	assert.NoError(t, err)
+
+	_, err = w.Write(plaintext)
+	assert.NoError(t, err)
+
+	err = w.Close()
+	assert.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+func decryptStream(c *Crypto, key, data []byte) ([]byte, error) {
+	r, err := c.NewReader(bytes.NewReader(data), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestStreamPreservesOriginalData(t *testing.T) {
+	c := New()
+	key, err := c.Key("consectetur-adipiscing-elit", "2026-10-17T10-00-00")
+	assert.NoError(t, err)
+
+	for _, size := range []int{0, 42, streamChunkSize, 3*streamChunkSize + 42} {
+		plaintext := make([]byte, size)
+		_, err := io.ReadFull(rand.Reader, plaintext)
+		assert.NoError(t, err)
+
+		data := encryptStream(t, c, key, plaintext)
+
+		decrypted, err := decryptStream(c, key, data)
+		assert.NoError(t, err)
+		assert.Equal(t, len(plaintext), len(decrypted))
+		assert.True(t, bytes.Equal(plaintext, decrypted))
+	}
+}
+
+func TestStreamDecryptionErrors(t *testing.T) {
+	c := New()
+	key, err := c.Key("consectetur-adipiscing-elit", "2026-10-17T10-00-00")
+	assert.NoError(t, err)
+
+	plaintext := make([]byte, 2*streamChunkSize+42)
+	data := encryptStream(t, c, key, plaintext)
+	prefixSize := len(streamMagic) + 1 + keyCheckSize + streamNoncePrefix
+	chunkSize := streamChunkSize + 16
+
+	other, err := c.Key("lorem-ipsum-dolor", "2026-10-17T10-00-00")
+	assert.NoError(t, err)
+
+	_, err = decryptStream(c, other, data)
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	_, err = decryptStream(c, key, data[:prefixSize-1])
+	assert.ErrorIs(t, err, ErrTruncated)
+
+	_, err = decryptStream(c, key, data[:prefixSize+chunkSize])
+	assert.ErrorIs(t, err, ErrTruncated)
+
+	_, err = decryptStream(c, key, data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrAuthentication)
+
+	tampered := bytes.Clone(data)
+	tampered[prefixSize+chunkSize] ^= 1
+	_, err = decryptStream(c, key, tampered)
+	assert.ErrorIs(t, err, ErrAuthentication)
+
+	reordered := bytes.Clone(data)
+	copy(reordered[prefixSize:], data[prefixSize+chunkSize:prefixSize+2*chunkSize])
+	copy(reordered[prefixSize+chunkSize:], data[prefixSize:prefixSize+chunkSize])
+	_, err = decryptStream(c, key, reordered)
+	assert.ErrorIs(t, err, ErrAuthentication)
+
+	_, err = decryptStream(c, key, append([]byte("OVF"), data[len(streamMagic):]...))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
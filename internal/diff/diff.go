@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Operation int
+
+const (
+	Equal Operation = iota
+	Insert
+	Delete
+)
+
+type Edit struct {
+	Operation Operation
+	Line      string
+}
+
+func Lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// Compute returns the shortest edit script turning a into b, using the linear space variant of the Myers diff algorithm.
+func Compute(a, b []string) []Edit {
+	var edits []Edit
+	compute(a, b, &edits)
+	return edits
+}
+
+func compute(a, b []string, edits *[]Edit) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-suffix-1] == b[len(b)-suffix-1] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		*edits = append(*edits, Edit{Operation: Equal, Line: line})
+	}
+
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch x, y, ok := bisect(middleA, middleB); {
+	case ok:
+		compute(middleA[:x], middleB[:y], edits)
+		compute(middleA[x:], middleB[y:], edits)
+	default:
+		for _, line := range middleA {
+			*edits = append(*edits, Edit{Operation: Delete, Line: line})
+		}
+		for _, line := range middleB {
+			*edits = append(*edits, Edit{Operation: Insert, Line: line})
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		*edits = append(*edits, Edit{Operation: Equal, Line: line})
+	}
+}
+
+// bisect finds the middle snake of the shortest edit script turning a into b by searching forward and backward at once,
+// and returns the point splitting it into two smaller problems, or false when there is no common line to split on.
+func bisect(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	odd := delta%2 != 0
+
+	var forwardStart, forwardEnd, backwardStart, backwardEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				if i := offset + delta - k; i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				if i := offset + delta - k; i >= 0 && i < len(forward) && forward[i] != -1 && forward[i] >= n-x {
+					return forward[i], forward[i] - (i - offset), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+func Stat(edits []Edit) (int, int) {
+	var insertions, deletions int
+	for _, edit := range edits {
+		switch edit.Operation {
+		case Insert:
+			insertions++
+		case Delete:
+			deletions++
+		}
+	}
+
+	return insertions, deletions
+}
+
+// Unified renders the edits as a unified diff with the given number of context lines around each change.
+func Unified(fromName, toName string, edits []Edit, context int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+
+	fromLines := make([]int, len(edits)+1)
+	toLines := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		fromLines[i+1] = fromLines[i]
+		toLines[i+1] = toLines[i]
+		if edit.Operation != Insert {
+			fromLines[i+1]++
+		}
+		if edit.Operation != Delete {
+			toLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].Operation == Equal {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := max(i-context, 0)
+		end := i
+		for {
+			for end < len(edits) && edits[end].Operation != Equal {
+				end++
+			}
+
+			next := end
+			for next < len(edits) && edits[next].Operation == Equal {
+				next++
+			}
+
+			if next < len(edits) && next-end <= 2*context {
+				end = next
+				continue
+			}
+
+			end = min(end+context, next)
+			break
+		}
+
+		writeHunk(&b, edits[start:end], fromLines[start], fromLines[end]-fromLines[start], toLines[start], toLines[end]-toLines[start])
+		i = end
+	}
+
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, edits []Edit, fromStart, fromCount, toStart, toCount int) {
+	if fromCount > 0 {
+		fromStart++
+	}
+	if toCount > 0 {
+		toStart++
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+	for _, edit := range edits {
+		prefix := " "
+		switch edit.Operation {
+		case Insert:
+			prefix = "+"
+		case Delete:
+			prefix = "-"
+		}
+
+		b.WriteString(prefix + edit.Line)
+		if !strings.HasSuffix(edit.Line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
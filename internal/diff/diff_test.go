@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	assert.Empty(t, Lines(""))
+	assert.Equal(t, []string{"Lorem\n", "ipsum\n"}, Lines("Lorem\nipsum\n"))
+	assert.Equal(t, []string{"Lorem\n", "ipsum"}, Lines("Lorem\nipsum"))
+}
+
+func TestComputeRebuildsBothSides(t *testing.T) {
+	a := Lines("Lorem\nipsum\ndolor\nsit\namet\n")
+	b := Lines("Lorem\ndolor\nsit\nconsectetur\namet\nelit\n")
+
+	edits := Compute(a, b)
+
+	var before, after strings.Builder
+	for _, edit := range edits {
+		if edit.Operation != Insert {
+			before.WriteString(edit.Line)
+		}
+		if edit.Operation != Delete {
+			after.WriteString(edit.Line)
+		}
+	}
+
+	assert.Equal(t, strings.Join(a, ""), before.String())
+	assert.Equal(t, strings.Join(b, ""), after.String())
+
+	insertions, deletions := Stat(edits)
+	assert.Equal(t, 2, insertions)
+	assert.Equal(t, 1, deletions)
+}
+
+func TestComputeEmpty(t *testing.T) {
+	assert.Empty(t, Compute(nil, nil))
+
+	insertions, deletions := Stat(Compute(nil, Lines("Lorem\nipsum\n")))
+	assert.Equal(t, 2, insertions)
+	assert.Equal(t, 0, deletions)
+
+	insertions, deletions = Stat(Compute(Lines("Lorem\nipsum\n"), nil))
+	assert.Equal(t, 0, insertions)
+	assert.Equal(t, 2, deletions)
+}
+
+func TestComputeRewrite(t *testing.T) {
+	a := make([]string, 10000)
+	b := make([]string, 10000)
+	for i := range a {
+		a[i] = fmt.Sprintf("Lorem %d\n", i)
+		b[i] = fmt.Sprintf("ipsum %d\n", i)
+	}
+	b[5000] = a[5000]
+
+	insertions, deletions := Stat(Compute(a, b))
+	assert.Equal(t, 9999, insertions)
+	assert.Equal(t, 9999, deletions)
+}
+
+func TestUnified(t *testing.T) {
+	a := Lines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n")
+	b := Lines("1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13")
+
+	expected := `--- a/note.md
++++ b/note.md
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+\ No newline at end of file
+`
+	assert.Equal(t, expected, Unified("a/note.md", "b/note.md", Compute(a, b), 3))
+}
+
+func TestUnifiedNewFile(t *testing.T) {
+	expected := `--- /dev/null
++++ b/note.md
+@@ -0,0 +1,2 @@
++Lorem
++ipsum
+`
+	assert.Equal(t, expected, Unified("/dev/null", "b/note.md", Compute(nil, Lines("Lorem\nipsum\n")), 3))
+}
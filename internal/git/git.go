@@ -1,9 +1,16 @@
 package git
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jhandguy/obsidian-vault/internal/cmd"
 )
@@ -19,9 +26,56 @@ func New(shell, path string) *Git {
 
 const HiddenFolder = ".git"
 
+// Upstream is the remote branch that Fetch updates.
+const Upstream = "origin/main"
+
+// Head and MergeHead are the local and remote sides of a merge left unfinished by a pull.
+const (
+	Head      = "HEAD"
+	MergeHead = "MERGE_HEAD"
+)
+
+type Change struct {
+	Status string
+	Path   string
+}
+
+type Commit struct {
+	Hash    string
+	Date    time.Time
+	Subject string
+}
+
+type Tag struct {
+	Name    string
+	Commit  string
+	Date    time.Time
+	Message string
+}
+
+type Object struct {
+	Hash string
+	Path string
+	Size int64
+}
+
+// Conflict is one side of a path left unmerged by a pull, where Stage is 1 for the common ancestor,
+// 2 for the local side and 3 for the remote side.
+type Conflict struct {
+	Hash  string
+	Path  string
+	Stage int
+}
+
+const (
+	StatusAdded    = "A"
+	StatusModified = "M"
+	StatusDeleted  = "D"
+)
+
 func (g *Git) Add(stdout, stderr io.Writer) error {
 	folder := filepath.Join(g.path, HiddenFolder)
-	command := fmt.Sprintf("git --git-dir %s --work-tree %s add .", folder, g.path)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s add .", quote(folder), quote(g.path))
 	err := cmd.Run(g.shell, command, stdout, stderr)
 	if err != nil {
 		return fmt.Errorf("failed to add git changes: %v", err)
@@ -30,9 +84,21 @@ func (g *Git) Add(stdout, stderr io.Writer) error {
 	return nil
 }
 
+func (g *Git) HasChanges(stderr io.Writer) (bool, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s status --porcelain", quote(folder), quote(g.path))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return false, fmt.Errorf("failed to get git status: %v", err)
+	}
+
+	return stdout.Len() > 0, nil
+}
+
 func (g *Git) Commit(stdout, stderr io.Writer, msg string) error {
 	folder := filepath.Join(g.path, HiddenFolder)
-	command := fmt.Sprintf("git --git-dir %s --work-tree %s commit -m \"%s\"", folder, g.path, msg)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s commit -m %s", quote(folder), quote(g.path), quote(msg))
 	err := cmd.Run(g.shell, command, stdout, stderr)
 	if err != nil {
 		return fmt.Errorf("failed to commit git changes: %v", err)
@@ -43,7 +109,7 @@ func (g *Git) Commit(stdout, stderr io.Writer, msg string) error {
 
 func (g *Git) Push(stdout, stderr io.Writer) error {
 	folder := filepath.Join(g.path, HiddenFolder)
-	command := fmt.Sprintf("git --git-dir %s --work-tree %s push origin main", folder, g.path)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s push origin main", quote(folder), quote(g.path))
 	err := cmd.Run(g.shell, command, stdout, stderr)
 	if err != nil {
 		return fmt.Errorf("failed to push git changes: %v", err)
@@ -52,9 +118,59 @@ func (g *Git) Push(stdout, stderr io.Writer) error {
 	return nil
 }
 
+func (g *Git) Tag(stdout, stderr io.Writer, name, rev, msg string, force bool) error {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s tag -a %s -m %s %s", quote(folder), quote(g.path), quote(name), quote(msg), quote(rev))
+	if force {
+		command += " --force"
+	}
+	err := cmd.Run(g.shell, command, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to create git tag: %v", err)
+	}
+
+	return nil
+}
+
+func (g *Git) PushTag(stdout, stderr io.Writer, name string, force bool) error {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s push origin %s", quote(folder), quote(g.path), quote("refs/tags/"+name))
+	if force {
+		command += " --force"
+	}
+	err := cmd.Run(g.shell, command, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to push git tag: %v", err)
+	}
+
+	return nil
+}
+
+func (g *Git) ForcePush(stdout, stderr io.Writer, lease string) error {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s push %s origin main", quote(folder), quote(g.path), quote("--force-with-lease=main:"+lease))
+	err := cmd.Run(g.shell, command, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to force push git changes: %v", err)
+	}
+
+	return nil
+}
+
+func (g *Git) Reset(stdout, stderr io.Writer, rev string) error {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s reset --soft %s", quote(folder), quote(g.path), quote(rev))
+	err := cmd.Run(g.shell, command, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to reset git branch: %v", err)
+	}
+
+	return nil
+}
+
 func (g *Git) Pull(stdout, stderr io.Writer) error {
 	folder := filepath.Join(g.path, HiddenFolder)
-	command := fmt.Sprintf("git --git-dir %s --work-tree %s pull origin main", folder, g.path)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s pull --no-rebase --ff --no-edit origin main", quote(folder), quote(g.path))
 	err := cmd.Run(g.shell, command, stdout, stderr)
 	if err != nil {
 		return fmt.Errorf("failed to pull git changes: %v", err)
@@ -62,3 +178,355 @@ func (g *Git) Pull(stdout, stderr io.Writer) error {
 
 	return nil
 }
+
+func (g *Git) Fetch(stdout, stderr io.Writer) error {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s fetch origin main", quote(folder), quote(g.path))
+	err := cmd.Run(g.shell, command, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to fetch git changes: %v", err)
+	}
+
+	return nil
+}
+
+// Related reports whether both revisions share a common ancestor, which they no longer do once history was rewritten.
+func (g *Git) Related(stderr io.Writer, rev, other string) (bool, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s merge-base %s %s", quote(folder), quote(g.path), quote(rev), quote(other))
+	err := cmd.Run(g.shell, command, io.Discard, stderr)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get git merge base: %v", err)
+	}
+
+	return true, nil
+}
+
+// MergeBase returns the best common ancestor of both revisions, or "" when they have none.
+func (g *Git) MergeBase(stderr io.Writer, rev, other string) (string, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s merge-base %s %s", quote(folder), quote(g.path), quote(rev), quote(other))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get git merge base: %v", err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *Git) Conflicts(stderr io.Writer) ([]Conflict, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s ls-files -u -z", quote(folder), quote(g.path))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git conflicts: %v", err)
+	}
+
+	return parseConflicts(stdout.String()), nil
+}
+
+func (g *Git) AbortMerge(stdout, stderr io.Writer) error {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s merge --abort", quote(folder), quote(g.path))
+	err := cmd.Run(g.shell, command, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to abort git merge: %v", err)
+	}
+
+	return nil
+}
+
+func (g *Git) Head(stderr io.Writer) (string, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s rev-parse HEAD", quote(folder), quote(g.path))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to get git head: %v", err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *Git) Resolve(stderr io.Writer, rev string) (string, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s rev-parse --verify %s", quote(folder), quote(g.path), quote(rev+"^{commit}"))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve git revision %s: %v", rev, err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *Git) Before(stderr io.Writer, date time.Time) (string, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s rev-list -1 %s HEAD", quote(folder), quote(g.path), quote("--before="+date.Format(time.RFC3339)))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to get git commit before %s: %v", date.Format(time.DateTime), err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *Git) Diff(stderr io.Writer, from, to string) ([]Change, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s diff --name-status --no-renames -z %s %s", quote(folder), quote(g.path), quote(from), quote(to))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff git commits: %v", err)
+	}
+
+	var changes []Change
+	fields := strings.Split(stdout.String(), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		changes = append(changes, Change{Status: fields[i], Path: fields[i+1]})
+	}
+
+	return changes, nil
+}
+
+func (g *Git) Log(stderr io.Writer, path string) ([]Commit, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s log --format=%%H%%x09%%cI%%x09%%s -- %s", quote(folder), quote(g.path), quote(path))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git log: %v", err)
+	}
+
+	return parseLog(stdout.String()), nil
+}
+
+func (g *Git) History(stderr io.Writer) ([]Commit, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s log --format=%%H%%x09%%cI%%x09%%s HEAD", quote(folder), quote(g.path))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git history: %v", err)
+	}
+
+	return parseLog(stdout.String()), nil
+}
+
+func (g *Git) CommitTree(stderr io.Writer, commit Commit, parent string) (string, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	date := commit.Date.Format(time.RFC3339)
+	command := fmt.Sprintf("GIT_AUTHOR_DATE=%s GIT_COMMITTER_DATE=%s git --git-dir %s --work-tree %s commit-tree %s -m %s", quote(date), quote(date), quote(folder), quote(g.path), quote(commit.Hash+"^{tree}"), quote(commit.Subject))
+	if parent != "" {
+		command += fmt.Sprintf(" -p %s", quote(parent))
+	}
+
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to create git commit: %v", err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *Git) Tree(stderr io.Writer, rev, path string) ([]Object, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s ls-tree -r -l -z %s -- %s", quote(folder), quote(g.path), quote(rev), quote(path))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git tree: %v", err)
+	}
+
+	return parseTree(stdout.String()), nil
+}
+
+func (g *Git) Blob(stderr io.Writer, hash string) ([]byte, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s cat-file blob %s", quote(folder), quote(g.path), quote(hash))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git blob: %v", err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Blobs reads the content of each blob in a single git process, passing them to fn one at a time in order.
+func (g *Git) Blobs(stderr io.Writer, hashes []string, fn func(hash string, data []byte) error) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s cat-file --batch", quote(folder), quote(g.path))
+	stdin := strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.RunWithInput(g.shell, command, stdin, writer, stderr)
+		writer.CloseWithError(err)
+		done <- err
+	}()
+
+	err := readBatch(bufio.NewReader(reader), fn)
+	reader.Close()
+	if runErr := <-done; runErr != nil && err == nil {
+		return fmt.Errorf("failed to get git blobs: %v", runErr)
+	}
+
+	return err
+}
+
+func (g *Git) Tags(stderr io.Writer, prefix string) ([]Tag, error) {
+	folder := filepath.Join(g.path, HiddenFolder)
+	command := fmt.Sprintf("git --git-dir %s --work-tree %s for-each-ref %s --sort=creatordate --format=\"%%(refname:short)%%09%%(*objectname)%%09%%(creatordate:iso-strict)%%09%%(contents)%%00\"", quote(folder), quote(g.path), quote("refs/tags/"+prefix))
+	var stdout bytes.Buffer
+	err := cmd.Run(g.shell, command, &stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git tags: %v", err)
+	}
+
+	return parseTags(stdout.String()), nil
+}
+
+// quote escapes a value for the shell, so that paths, revisions and messages are passed as is and never expanded.
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func parseLog(output string) []Commit {
+	var commits []Commit
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			continue
+		}
+
+		commits = append(commits, Commit{Hash: fields[0], Date: date, Subject: fields[2]})
+	}
+
+	return commits
+}
+
+func parseTree(output string) []Object {
+	var objects []Object
+	for _, entry := range strings.Split(output, "\x00") {
+		info, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(info)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		objects = append(objects, Object{Hash: fields[2], Path: path, Size: size})
+	}
+
+	return objects
+}
+
+// readBatch reads the output of git cat-file --batch, where each blob is a header line
+// with its hash, type and size followed by its content and a line feed.
+func readBatch(r *bufio.Reader, fn func(hash string, data []byte) error) error {
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read git blob header: %w", err)
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return fmt.Errorf("failed to get git blob: %s", strings.TrimSpace(header))
+		}
+
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse git blob size %s: %w", fields[2], err)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("failed to read git blob %s: %w", fields[0], err)
+		}
+
+		if _, err := r.Discard(1); err != nil {
+			return fmt.Errorf("failed to read git blob %s: %w", fields[0], err)
+		}
+
+		if err := fn(fields[0], data); err != nil {
+			return err
+		}
+	}
+}
+
+func parseConflicts(output string) []Conflict {
+	var conflicts []Conflict
+	for _, entry := range strings.Split(output, "\x00") {
+		info, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(info)
+		if len(fields) != 3 {
+			continue
+		}
+
+		stage, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+
+		conflicts = append(conflicts, Conflict{Hash: fields[1], Path: path, Stage: stage})
+	}
+
+	return conflicts
+}
+
+func parseTags(output string) []Tag {
+	var tags []Tag
+	for _, record := range strings.Split(output, "\x00") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			continue
+		}
+
+		tags = append(tags, Tag{Name: fields[0], Commit: fields[1], Date: date, Message: fields[3]})
+	}
+
+	return tags
+}
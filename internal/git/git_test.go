@@ -1,17 +1,22 @@
 package git
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 var tmpPath = filepath.Clean("/tmp")
 var git = New("echo", tmpPath)
-var gitCommand = fmt.Sprintf("git --git-dir %s --work-tree %s", filepath.Join(tmpPath, HiddenFolder), tmpPath)
+var gitCommand = fmt.Sprintf("git --git-dir '%s' --work-tree '%s'", filepath.Join(tmpPath, HiddenFolder), tmpPath)
 
 func TestAdd(t *testing.T) {
 	var stdout bytes.Buffer
@@ -22,13 +27,21 @@ func TestAdd(t *testing.T) {
 	assert.Empty(t, stderr.String())
 }
 
+func TestHasChanges(t *testing.T) {
+	var stderr bytes.Buffer
+	changes, err := git.HasChanges(&stderr)
+	assert.NoError(t, err)
+	assert.True(t, changes)
+	assert.Empty(t, stderr.String())
+}
+
 func TestCommit(t *testing.T) {
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	msg := "commit message"
 	err := git.Commit(&stdout, &stderr, msg)
 	assert.NoError(t, err)
-	assert.Equal(t, fmt.Sprintf("-c %s commit -m \"%s\"\n", gitCommand, msg), stdout.String())
+	assert.Equal(t, fmt.Sprintf("-c %s commit -m '%s'\n", gitCommand, msg), stdout.String())
 	assert.Empty(t, stderr.String())
 }
 
@@ -41,11 +54,268 @@ func TestPush(t *testing.T) {
 	assert.Empty(t, stderr.String())
 }
 
+func TestTag(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err := git.Tag(&stdout, &stderr, "snapshot/name", "HEAD", "tag message", true)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s tag -a 'snapshot/name' -m 'tag message' 'HEAD' --force\n", gitCommand), stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestPushTag(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err := git.PushTag(&stdout, &stderr, "snapshot/name", false)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s push origin 'refs/tags/snapshot/name'\n", gitCommand), stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestForcePush(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err := git.ForcePush(&stdout, &stderr, "2f1e3d7")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s push '--force-with-lease=main:2f1e3d7' origin main\n", gitCommand), stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestReset(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err := git.Reset(&stdout, &stderr, "2f1e3d7")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s reset --soft '2f1e3d7'\n", gitCommand), stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
 func TestPull(t *testing.T) {
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	err := git.Pull(&stdout, &stderr)
 	assert.NoError(t, err)
-	assert.Equal(t, fmt.Sprintf("-c %s pull origin main\n", gitCommand), stdout.String())
+	assert.Equal(t, fmt.Sprintf("-c %s pull --no-rebase --ff --no-edit origin main\n", gitCommand), stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestFetch(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err := git.Fetch(&stdout, &stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s fetch origin main\n", gitCommand), stdout.String())
 	assert.Empty(t, stderr.String())
 }
+
+func TestRelated(t *testing.T) {
+	var stderr bytes.Buffer
+	related, err := git.Related(&stderr, "HEAD", Upstream)
+	assert.NoError(t, err)
+	assert.True(t, related)
+	assert.Empty(t, stderr.String())
+}
+
+func TestConflicts(t *testing.T) {
+	var stderr bytes.Buffer
+	conflicts, err := git.Conflicts(&stderr)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Empty(t, stderr.String())
+}
+
+func TestParseConflicts(t *testing.T) {
+	output := "100644 8ab686e 1\t.ovmanifest\x00100644 9c1f2a3 2\t.ovmanifest\x00100644 2f1e3d7 3\t.ovmanifest\x00"
+	conflicts := parseConflicts(output)
+	assert.Equal(t, []Conflict{{Hash: "8ab686e", Path: ".ovmanifest", Stage: 1}, {Hash: "9c1f2a3", Path: ".ovmanifest", Stage: 2}, {Hash: "2f1e3d7", Path: ".ovmanifest", Stage: 3}}, conflicts)
+}
+
+func TestAbortMerge(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err := git.AbortMerge(&stdout, &stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s merge --abort\n", gitCommand), stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestHead(t *testing.T) {
+	var stderr bytes.Buffer
+	head, err := git.Head(&stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s rev-parse HEAD", gitCommand), head)
+	assert.Empty(t, stderr.String())
+}
+
+func TestResolve(t *testing.T) {
+	var stderr bytes.Buffer
+	commit, err := git.Resolve(&stderr, "snapshot/name")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s rev-parse --verify 'snapshot/name^{commit}'", gitCommand), commit)
+	assert.Empty(t, stderr.String())
+}
+
+func TestBefore(t *testing.T) {
+	var stderr bytes.Buffer
+	date := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	commit, err := git.Before(&stderr, date)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s rev-list -1 '--before=2026-09-01T00:00:00Z' HEAD", gitCommand), commit)
+	assert.Empty(t, stderr.String())
+}
+
+func TestDiff(t *testing.T) {
+	var stderr bytes.Buffer
+	changes, err := git.Diff(&stderr, "HEAD~1", "HEAD")
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Empty(t, stderr.String())
+}
+
+func TestLog(t *testing.T) {
+	var stderr bytes.Buffer
+	commits, err := git.Log(&stderr, "folder/note.md")
+	assert.NoError(t, err)
+	assert.Empty(t, commits)
+	assert.Empty(t, stderr.String())
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "'folder/note.md'", quote("folder/note.md"))
+	assert.Equal(t, `'it'\''s "$HOME" `+"`id`'", quote(`it's "$HOME" `+"`id`"))
+}
+
+func TestParseLog(t *testing.T) {
+	output := "2f1e3d7\t2026-10-17T10:00:00+02:00\t[2026-10-17 10:00:00] obsidian-vault backup\n"
+	commits := parseLog(output)
+	assert.Len(t, commits, 1)
+	assert.Equal(t, "2f1e3d7", commits[0].Hash)
+	assert.True(t, time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC).Equal(commits[0].Date))
+	assert.Equal(t, "[2026-10-17 10:00:00] obsidian-vault backup", commits[0].Subject)
+}
+
+func TestTree(t *testing.T) {
+	var stderr bytes.Buffer
+	objects, err := git.Tree(&stderr, "HEAD", "folder")
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+	assert.Empty(t, stderr.String())
+}
+
+func TestParseTree(t *testing.T) {
+	output := "100644 blob 8ab686e    42\tfolder/note 1.md\x00040000 tree 9c1f2a3       -\tfolder/sub\x00"
+	objects := parseTree(output)
+	assert.Equal(t, []Object{{Hash: "8ab686e", Path: "folder/note 1.md", Size: 42}}, objects)
+}
+
+func TestBlob(t *testing.T) {
+	var stderr bytes.Buffer
+	blob, err := git.Blob(&stderr, "8ab686e")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c %s cat-file blob '8ab686e'\n", gitCommand), string(blob))
+	assert.Empty(t, stderr.String())
+}
+
+func TestReadBatch(t *testing.T) {
+	output := "8ab686e blob 11\nLorem ipsum\n9c1f2a3 blob 0\n\n"
+	blobs := map[string]string{}
+	err := readBatch(bufio.NewReader(strings.NewReader(output)), func(hash string, data []byte) error {
+		blobs[hash] = string(data)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"8ab686e": "Lorem ipsum", "9c1f2a3": ""}, blobs)
+
+	err = readBatch(bufio.NewReader(strings.NewReader("8ab686e missing\n")), func(string, []byte) error {
+		return nil
+	})
+	assert.Error(t, err)
+
+	err = readBatch(bufio.NewReader(strings.NewReader("8ab686e blob 11\nLorem\n")), func(string, []byte) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func TestTags(t *testing.T) {
+	var stderr bytes.Buffer
+	tags, err := git.Tags(&stderr, "snapshot/")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+	assert.Empty(t, stderr.String())
+}
+
+func TestParseTags(t *testing.T) {
+	output := "snapshot/name\t2f1e3d7\t2026-10-17T10:00:00+02:00\ttag message\n\nfiles: 42\n\x00\n"
+	tags := parseTags(output)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "snapshot/name", tags[0].Name)
+	assert.Equal(t, "2f1e3d7", tags[0].Commit)
+	assert.True(t, time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC).Equal(tags[0].Date))
+	assert.Equal(t, "tag message\n\nfiles: 42\n", tags[0].Message)
+}
+
+func TestHistory(t *testing.T) {
+	var stderr bytes.Buffer
+	commits, err := git.History(&stderr)
+	assert.NoError(t, err)
+	assert.Empty(t, commits)
+	assert.Empty(t, stderr.String())
+}
+
+func TestCommitTree(t *testing.T) {
+	var stderr bytes.Buffer
+	commit := Commit{Hash: "2f1e3d7", Date: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), Subject: "commit message"}
+	hash, err := git.CommitTree(&stderr, commit, "9c1f2a3")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("-c GIT_AUTHOR_DATE='2026-10-17T10:00:00Z' GIT_COMMITTER_DATE='2026-10-17T10:00:00Z' %s commit-tree '2f1e3d7^{tree}' -m 'commit message' -p '9c1f2a3'", gitCommand), hash)
+	assert.Empty(t, stderr.String())
+}
+
+func TestSpecialCharacters(t *testing.T) {
+	path := t.TempDir()
+	git := New("sh", path)
+	name := "it's \"$HOME\" `id`.md"
+
+	for _, command := range []string{"init -q -b main", "config user.email ov@example.com", "config user.name ov"} {
+		err := exec.Command("sh", "-c", fmt.Sprintf("git -C %s %s", quote(path), command)).Run()
+		assert.NoError(t, err)
+	}
+
+	err := os.WriteFile(filepath.Join(path, name), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	err = git.Add(&stdout, &stderr)
+	assert.NoError(t, err)
+
+	err = git.Commit(&stdout, &stderr, "it's `id`")
+	assert.NoError(t, err)
+
+	commits, err := git.Log(&stderr, name)
+	assert.NoError(t, err)
+	assert.Len(t, commits, 1)
+	assert.Equal(t, "it's `id`", commits[0].Subject)
+
+	objects, err := git.Tree(&stderr, "HEAD", name)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+	assert.Equal(t, name, objects[0].Path)
+
+	var blobs []string
+	err = git.Blobs(&stderr, []string{objects[0].Hash}, func(_ string, data []byte) error {
+		blobs = append(blobs, string(data))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lorem ipsum"}, blobs)
+
+	err = git.Tag(&stdout, &stderr, "snapshot/$name", "HEAD", "it's `id`", false)
+	assert.NoError(t, err)
+
+	tags, err := git.Tags(&stderr, "snapshot/")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "snapshot/$name", tags[0].Name)
+}
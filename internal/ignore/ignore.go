@@ -0,0 +1,187 @@
+package ignore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const FileName = ".ovignore"
+
+type pattern struct {
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+type Matcher struct {
+	patterns []pattern
+}
+
+func Load(path string) (*Matcher, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Parse(""), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file %s: %w", path, err)
+	}
+
+	return Parse(string(data)), nil
+}
+
+// Parse compiles patterns following the gitignore format: blank lines and comments are skipped,
+// a leading "!" negates, a trailing "/" only matches directories, a slash anywhere else anchors
+// the pattern to the root and "*", "?", "[...]" and "**" behave as in git.
+func Parse(content string) *Matcher {
+	m := &Matcher{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		line = trimTrailingSpaces(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p pattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		expression := compile(line)
+		if anchored {
+			expression = "^" + expression + "$"
+		} else {
+			expression = "^(?:.*/)?" + expression + "$"
+		}
+
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			continue
+		}
+		p.regexp = re
+
+		m.patterns = append(m.patterns, p)
+	}
+
+	return m
+}
+
+// String lists the compiled patterns one per line, so that two files ignoring the same paths compare equal
+// regardless of their comments and blank lines.
+func (m *Matcher) String() string {
+	var b strings.Builder
+	for _, p := range m.patterns {
+		if p.negate {
+			b.WriteString("!")
+		}
+		b.WriteString(p.regexp.String())
+		if p.dirOnly {
+			b.WriteString("/")
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func (m *Matcher) Empty() bool {
+	return len(m.patterns) == 0
+}
+
+// Match reports whether the relative path is ignored, either directly or because one of its parent directories is.
+func (m *Matcher) Match(relativePath string, isDir bool) bool {
+	if m.Empty() {
+		return false
+	}
+
+	relativePath = filepath.ToSlash(relativePath)
+	for dir := path.Dir(relativePath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if m.match(dir, true) {
+			return true
+		}
+	}
+
+	return m.match(relativePath, isDir)
+}
+
+func (m *Matcher) match(relativePath string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		if p.regexp.MatchString(relativePath) {
+			ignored = !p.negate
+		}
+	}
+
+	return ignored
+}
+
+func compile(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case glob[i:] == "**" && i > 0 && glob[i-1] == '/':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	return strings.ReplaceAll(line, `\ `, " ")
+}
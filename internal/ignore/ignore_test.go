@@ -0,0 +1,82 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	m := Parse(`
+# exports and caches
+*.pdf
+!keep.pdf
+.trash/
+/Draw.canvas
+folder-1/*.tmp
+**/cache/**
+docs/**/draft.md
+Templates/
+file\ name.md
+[ab]?.md
+`)
+
+	cases := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"export.pdf", false, true},
+		{"folder-1/export.pdf", false, true},
+		{"folder-1/keep.pdf", false, false},
+		{".trash", true, true},
+		{".trash/note.md", false, true},
+		{".trash", false, false},
+		{"Draw.canvas", false, true},
+		{"folder-1/Draw.canvas", false, false},
+		{"folder-1/note.tmp", false, true},
+		{"folder-1/folder-2/note.tmp", false, false},
+		{".obsidian/plugins/cache/data.json", false, true},
+		{"cache/data.json", false, true},
+		{"docs/draft.md", false, true},
+		{"docs/a/b/draft.md", false, true},
+		{"docs/a/b/final.md", false, false},
+		{"Templates/Daily.md", false, true},
+		{"file name.md", false, true},
+		{"a1.md", false, true},
+		{"c1.md", false, false},
+		{"folder-1/File-1.md", false, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.ignored, m.Match(filepath.FromSlash(c.path), c.isDir), c.path)
+	}
+}
+
+func TestMatchCannotReincludeInsideIgnoredDirectory(t *testing.T) {
+	m := Parse("folder-1/\n!folder-1/File-1.md\n")
+	assert.True(t, m.Match(filepath.Join("folder-1", "File-1.md"), false))
+}
+
+func TestLoad(t *testing.T) {
+	m, err := Load(filepath.Join(t.TempDir(), FileName))
+	assert.NoError(t, err)
+	assert.True(t, m.Empty())
+
+	path := filepath.Join(t.TempDir(), FileName)
+	err = os.WriteFile(path, []byte("*.pdf\n"), 0644)
+	assert.NoError(t, err)
+
+	m, err = Load(path)
+	assert.NoError(t, err)
+	assert.False(t, m.Empty())
+	assert.True(t, m.Match("export.pdf", false))
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, Parse("*.pdf\n!keep.pdf\n").String(), Parse("# exports\n*.pdf\n\n!keep.pdf").String())
+	assert.NotEqual(t, Parse("*.pdf\n").String(), Parse("*.pdf/\n").String())
+	assert.Empty(t, Parse("").String())
+}
@@ -0,0 +1,126 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type File struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	Hash    string      `json:"hash"`
+}
+
+// Manifest records the state of the last sync, where Sequence is the last remote manifest sequence number seen
+// and Selection identifies the folders, config profile and exclude rules the files were synced with.
+type Manifest struct {
+	Commit    string          `json:"commit"`
+	Sequence  uint64          `json:"sequence,omitempty"`
+	Selection string          `json:"selection,omitempty"`
+	Files     map[string]File `json:"files"`
+	mutex     sync.Mutex
+}
+
+func New() *Manifest {
+	return &Manifest{Files: map[string]File{}}
+}
+
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	m := New()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	if m.Files == nil {
+		m.Files = map[string]File{}
+	}
+
+	return m, nil
+}
+
+func (m *Manifest) Save(path string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (m *Manifest) Get(name string) (File, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, ok := m.Files[name]
+	return file, ok
+}
+
+func (m *Manifest) Set(name string, file File) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Files[name] = file
+}
+
+func (m *Manifest) Delete(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.Files, name)
+}
+
+func (m *Manifest) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.Files)
+}
+
+// Unchanged reports whether the file info matches the recorded size, modification time and executable bits.
+func (f File) Unchanged(info fs.FileInfo) bool {
+	return f.Size == info.Size() && f.ModTime.Equal(info.ModTime()) && Executable(f.Mode) == Executable(info.Mode())
+}
+
+// Executable returns the executable bits of a mode, which are the only permissions synced across devices,
+// as the others depend on the umask of each device and Windows reports every writable file as 0666.
+func Executable(mode fs.FileMode) fs.FileMode {
+	return mode.Perm() & 0111
+}
+
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
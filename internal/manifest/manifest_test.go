@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndLoadPreserveFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	file := File{Size: 42, ModTime: time.Now(), Hash: Hash([]byte("Lorem ipsum"))}
+
+	m := New()
+	m.Set("LoremIpsum.md", file)
+	err := m.Save(path)
+	assert.NoError(t, err)
+
+	loaded, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loaded.Len())
+
+	entry, ok := loaded.Get("LoremIpsum.md")
+	assert.True(t, ok)
+	assert.Equal(t, file.Size, entry.Size)
+	assert.True(t, file.ModTime.Equal(entry.ModTime))
+	assert.Equal(t, file.Hash, entry.Hash)
+}
+
+func TestLoadMissingManifestIsEmpty(t *testing.T) {
+	m, err := Load(filepath.Join(t.TempDir(), "manifest.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "LoremIpsum.md")
+	err := os.WriteFile(path, []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	file := File{Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode().Perm()}
+	assert.True(t, file.Unchanged(info))
+
+	file.Mode = 0755
+	assert.False(t, file.Unchanged(info))
+
+	file.Mode = info.Mode().Perm() | 0022
+	assert.True(t, file.Unchanged(info))
+
+	file.Mode = info.Mode().Perm()
+	file.ModTime = file.ModTime.Add(-time.Second)
+	assert.False(t, file.Unchanged(info))
+}
@@ -0,0 +1,335 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tombstone records the deletion of a file, telling a file deleted on a device apart from a file it never had.
+type Tombstone struct {
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"`
+}
+
+// Remote is the manifest shared by all devices through the git vault, keyed by slash separated paths.
+// Files holds the hash of every encrypted file of the git vault, and Sequence increases with every change,
+// telling a git vault rolled back to an older state apart from the latest one.
+//
+// The hashes of the files of each top-level directory are kept apart in a shard, so that a change only rewrites
+// the shard of its directory along with the root, which holds the rest and the hash of every shard.
+type Remote struct {
+	Sequence   uint64               `json:"sequence"`
+	Files      map[string]string    `json:"files"`
+	Tombstones map[string]Tombstone `json:"tombstones"`
+	Shards     map[string]string    `json:"shards"`
+	changed    map[string]bool
+	mutex      sync.Mutex
+}
+
+// root is the encoding of the root of a remote manifest.
+type root struct {
+	Sequence   uint64               `json:"sequence"`
+	Files      map[string]string    `json:"files"`
+	Tombstones map[string]Tombstone `json:"tombstones"`
+	Shards     map[string]string    `json:"shards"`
+}
+
+// shardFiles is the encoding of a shard of a remote manifest.
+type shardFiles struct {
+	Files map[string]string `json:"files"`
+}
+
+func NewRemote() *Remote {
+	return &Remote{Files: map[string]string{}, Tombstones: map[string]Tombstone{}, Shards: map[string]string{}, changed: map[string]bool{}}
+}
+
+// ParseRemote parses the root of a remote manifest, whose shards are added with ParseShard. The files of a root
+// written before shards existed are moved into their shards.
+func ParseRemote(data []byte) (*Remote, error) {
+	r := NewRemote()
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse remote manifest: %w", err)
+	}
+
+	if r.Files == nil {
+		r.Files = map[string]string{}
+	}
+
+	if r.Tombstones == nil {
+		r.Tombstones = map[string]Tombstone{}
+	}
+
+	if r.Shards == nil {
+		r.Shards = map[string]string{}
+	}
+
+	for name := range r.Files {
+		if shard := ShardOf(name); shard != "" {
+			r.changed[shard] = true
+		}
+	}
+
+	return r, nil
+}
+
+// ParseShard adds the hashes of a shard of the manifest.
+func (r *Remote) ParseShard(shard string, data []byte) error {
+	var s shardFiles
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failed to parse remote manifest shard %s: %w", shard, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for name, hash := range s.Files {
+		if ShardOf(name) != shard {
+			return fmt.Errorf("failed to parse remote manifest shard %s: file %s belongs to another shard", shard, name)
+		}
+
+		r.Files[name] = hash
+	}
+
+	return nil
+}
+
+// Marshal encodes the root of the manifest, holding the hashes of the files at the root of the git vault.
+func (r *Remote) Marshal() ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	files := map[string]string{}
+	for name, hash := range r.Files {
+		if ShardOf(name) == "" {
+			files[name] = hash
+		}
+	}
+
+	data, err := json.Marshal(root{Sequence: r.Sequence, Files: files, Tombstones: r.Tombstones, Shards: r.Shards})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode remote manifest: %w", err)
+	}
+
+	return data, nil
+}
+
+// MarshalShard encodes a shard of the manifest, which is nil once its directory has no file left.
+func (r *Remote) MarshalShard(shard string) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	files := map[string]string{}
+	for name, hash := range r.Files {
+		if ShardOf(name) == shard {
+			files[name] = hash
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(shardFiles{Files: files})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode remote manifest shard %s: %w", shard, err)
+	}
+
+	return data, nil
+}
+
+// SetShard records the hash of an encrypted shard, or forgets the shard without a hash.
+func (r *Remote) SetShard(shard, hash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if hash == "" {
+		delete(r.Shards, shard)
+		return
+	}
+
+	r.Shards[shard] = hash
+}
+
+// Changed returns the shards changed since the manifest was parsed, sorted.
+func (r *Remote) Changed() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var shards []string
+	for _, shard := range slices.Sorted(maps.Keys(r.changed)) {
+		if shard != "" {
+			shards = append(shards, shard)
+		}
+	}
+
+	return shards
+}
+
+// Modified reports whether the manifest changed since it was parsed.
+func (r *Remote) Modified() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.changed) > 0
+}
+
+// ShardOf returns the shard holding the hash of a file, which is its top-level directory, or "" at the root.
+func ShardOf(name string) string {
+	shard, _, ok := strings.Cut(filepath.ToSlash(name), "/")
+	if !ok {
+		return ""
+	}
+
+	return shard
+}
+
+// Record records the hash of a file encrypted or verified in the git vault.
+func (r *Remote) Record(name, hash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if current, ok := r.Files[filepath.ToSlash(name)]; ok && current == hash {
+		return
+	}
+
+	r.Files[filepath.ToSlash(name)] = hash
+	r.changed[ShardOf(name)] = true
+}
+
+// Forget removes the hash of a file removed from the git vault, or no longer trusted.
+func (r *Remote) Forget(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Files[filepath.ToSlash(name)]; !ok {
+		return
+	}
+
+	delete(r.Files, filepath.ToSlash(name))
+	r.changed[ShardOf(name)] = true
+}
+
+// Advance moves to the next sequence number if the manifest changed since it was parsed.
+func (r *Remote) Advance() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.changed) > 0 {
+		r.Sequence++
+	}
+}
+
+func (r *Remote) File(name string) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, ok := r.Files[filepath.ToSlash(name)]
+	return hash, ok
+}
+
+func (r *Remote) Bury(name, device string, t time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Files[filepath.ToSlash(name)]; ok {
+		delete(r.Files, filepath.ToSlash(name))
+		r.changed[ShardOf(name)] = true
+	}
+	r.Tombstones[filepath.ToSlash(name)] = Tombstone{Time: t, Device: device}
+	r.changed[""] = true
+}
+
+func (r *Remote) Revive(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Tombstones[filepath.ToSlash(name)]; !ok {
+		return
+	}
+
+	delete(r.Tombstones, filepath.ToSlash(name))
+	r.changed[""] = true
+}
+
+func (r *Remote) Tombstone(name string) (Tombstone, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tombstone, ok := r.Tombstones[filepath.ToSlash(name)]
+	return tombstone, ok
+}
+
+// MergeRemote merges the manifests of two diverged git vaults with the one of their common ancestor,
+// which is empty when they have none. A hash or tombstone added, replaced or removed on a single side is taken
+// from that side, and the latest tombstone is kept when both sides replaced it. The merged manifest moves past
+// the sequence numbers of both sides, and every shard of either side is rewritten.
+func MergeRemote(base, ours, theirs *Remote) *Remote {
+	r := NewRemote()
+	r.Files = merge(base.Files, ours.Files, theirs.Files, func(a, b string) bool { return a == b }, func(_, t string) string { return t })
+	r.Tombstones = merge(base.Tombstones, ours.Tombstones, theirs.Tombstones, Tombstone.equal, func(o, t Tombstone) Tombstone {
+		if t.Time.After(o.Time) {
+			return t
+		}
+
+		return o
+	})
+	r.Sequence = max(ours.Sequence, theirs.Sequence) + 1
+	r.changed[""] = true
+	for _, side := range []*Remote{base, ours, theirs} {
+		for name := range side.Files {
+			r.changed[ShardOf(name)] = true
+		}
+
+		for shard := range side.Shards {
+			r.changed[shard] = true
+		}
+	}
+
+	return r
+}
+
+func (t Tombstone) equal(other Tombstone) bool {
+	return t.Device == other.Device && t.Time.Equal(other.Time)
+}
+
+// merge merges two maps with the one of their common ancestor, taking every key changed on a single side from that side,
+// and resolving the keys changed on both sides with pick, unless one side removed it.
+func merge[V any](base, ours, theirs map[string]V, equal func(a, b V) bool, pick func(ours, theirs V) V) map[string]V {
+	same := func(a V, okA bool, b V, okB bool) bool {
+		return okA == okB && (!okA || equal(a, b))
+	}
+
+	merged := map[string]V{}
+	for _, m := range []map[string]V{base, ours, theirs} {
+		for key := range m {
+			b, inBase := base[key]
+			o, inOurs := ours[key]
+			t, inTheirs := theirs[key]
+
+			switch {
+			case same(o, inOurs, t, inTheirs) || same(t, inTheirs, b, inBase):
+				if inOurs {
+					merged[key] = o
+				}
+			case same(o, inOurs, b, inBase):
+				if inTheirs {
+					merged[key] = t
+				}
+			case inOurs && inTheirs:
+				merged[key] = pick(o, t)
+			case inOurs:
+				merged[key] = o
+			default:
+				merged[key] = t
+			}
+		}
+	}
+
+	return merged
+}
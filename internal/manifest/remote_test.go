@@ -0,0 +1,194 @@
+package manifest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteTombstones(t *testing.T) {
+	name := filepath.Join("folder", "LoremIpsum.md")
+	deleted := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+
+	r := NewRemote()
+	r.Revive(name)
+	assert.False(t, r.Modified())
+
+	r.Bury(name, "laptop", deleted)
+	assert.True(t, r.Modified())
+
+	data, err := r.Marshal()
+	assert.NoError(t, err)
+
+	parsed, err := ParseRemote(data)
+	assert.NoError(t, err)
+	assert.False(t, parsed.Modified())
+
+	tombstone, ok := parsed.Tombstone(name)
+	assert.True(t, ok)
+	assert.Equal(t, "laptop", tombstone.Device)
+	assert.True(t, deleted.Equal(tombstone.Time))
+
+	parsed.Revive(name)
+	assert.True(t, parsed.Modified())
+
+	_, ok = parsed.Tombstone(name)
+	assert.False(t, ok)
+}
+
+func TestRemoteRecord(t *testing.T) {
+	name := filepath.Join("folder", "LoremIpsum.md")
+	hash := Hash([]byte("Lorem ipsum"))
+
+	r := NewRemote()
+	r.Forget(name)
+	r.Advance()
+	assert.False(t, r.Modified())
+	assert.Zero(t, r.Sequence)
+
+	r.Record(name, hash)
+	r.Advance()
+	assert.True(t, r.Modified())
+	assert.Equal(t, uint64(1), r.Sequence)
+
+	data, err := r.Marshal()
+	assert.NoError(t, err)
+
+	shard, err := r.MarshalShard("folder")
+	assert.NoError(t, err)
+
+	parsed, err := ParseRemote(data)
+	assert.NoError(t, err)
+	assert.Empty(t, parsed.Files)
+
+	err = parsed.ParseShard("folder", shard)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), parsed.Sequence)
+	assert.Equal(t, map[string]string{filepath.ToSlash(name): hash}, parsed.Files)
+
+	parsed.Record(name, hash)
+	parsed.Advance()
+	assert.False(t, parsed.Modified())
+	assert.Equal(t, uint64(1), parsed.Sequence)
+
+	recorded, ok := parsed.File(name)
+	assert.True(t, ok)
+	assert.Equal(t, hash, recorded)
+
+	parsed.Bury(name, "laptop", time.Now())
+	parsed.Advance()
+	assert.Equal(t, uint64(2), parsed.Sequence)
+
+	_, ok = parsed.File(name)
+	assert.False(t, ok)
+
+	parsed, err = ParseRemote(data)
+	assert.NoError(t, err)
+
+	err = parsed.ParseShard("folder", shard)
+	assert.NoError(t, err)
+
+	parsed.Forget(name)
+	assert.True(t, parsed.Modified())
+
+	_, ok = parsed.File(name)
+	assert.False(t, ok)
+}
+
+func TestMergeRemote(t *testing.T) {
+	earlier := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	base := NewRemote()
+	base.Bury("kept.md", "laptop", earlier)
+	base.Bury("revived.md", "laptop", earlier)
+	base.Bury("replaced.md", "laptop", earlier)
+
+	ours := NewRemote()
+	ours.Bury("kept.md", "laptop", earlier)
+	ours.Bury("replaced.md", "laptop", earlier)
+	ours.Bury("ours.md", "laptop", earlier)
+	ours.Bury("both.md", "laptop", later)
+
+	theirs := NewRemote()
+	theirs.Bury("kept.md", "laptop", earlier)
+	theirs.Bury("revived.md", "laptop", earlier)
+	theirs.Bury("replaced.md", "phone", later)
+	theirs.Bury("theirs.md", "phone", later)
+	theirs.Bury("both.md", "phone", earlier)
+
+	record := func(r *Remote, files map[string]string) {
+		for name, hash := range files {
+			r.Record(name, hash)
+		}
+		r.Advance()
+	}
+
+	record(base, map[string]string{"kept.md": "1", "edited.md": "1", "removed.md": "1"})
+	record(ours, map[string]string{"kept.md": "1", "edited.md": "2", "removed.md": "1", "ours.md": "1"})
+	record(ours, map[string]string{"edited.md": "3"})
+	record(theirs, map[string]string{"kept.md": "1", "edited.md": "1", "theirs.md": "1"})
+
+	merged := MergeRemote(base, ours, theirs)
+	assert.True(t, merged.Modified())
+	assert.Equal(t, uint64(3), merged.Sequence)
+	assert.Equal(t, map[string]string{"kept.md": "1", "edited.md": "3", "ours.md": "1", "theirs.md": "1"}, merged.Files)
+	assert.Len(t, merged.Tombstones, 5)
+
+	for name, device := range map[string]string{"kept.md": "laptop", "replaced.md": "phone", "ours.md": "laptop", "theirs.md": "phone", "both.md": "laptop"} {
+		tombstone, ok := merged.Tombstone(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, device, tombstone.Device, name)
+	}
+
+	_, ok := merged.Tombstone("revived.md")
+	assert.False(t, ok)
+}
+
+func TestRemoteShards(t *testing.T) {
+	r := NewRemote()
+	r.Record("root.md", "1")
+	r.Record("folder/nested/LoremIpsum.md", "2")
+	r.Record("other/LoremIpsum.md", "3")
+	assert.Equal(t, []string{"folder", "other"}, r.Changed())
+
+	r.SetShard("folder", "folder-hash")
+	r.SetShard("other", "other-hash")
+
+	data, err := r.Marshal()
+	assert.NoError(t, err)
+
+	shard, err := r.MarshalShard("folder")
+	assert.NoError(t, err)
+
+	parsed, err := ParseRemote(data)
+	assert.NoError(t, err)
+	assert.False(t, parsed.Modified())
+	assert.Equal(t, map[string]string{"root.md": "1"}, parsed.Files)
+	assert.Equal(t, map[string]string{"folder": "folder-hash", "other": "other-hash"}, parsed.Shards)
+
+	err = parsed.ParseShard("other", shard)
+	assert.ErrorContains(t, err, "belongs to another shard")
+
+	err = parsed.ParseShard("folder", shard)
+	assert.NoError(t, err)
+
+	parsed.Forget("folder/nested/LoremIpsum.md")
+	assert.Equal(t, []string{"folder"}, parsed.Changed())
+
+	shard, err = parsed.MarshalShard("folder")
+	assert.NoError(t, err)
+	assert.Nil(t, shard)
+
+	// a root written before shards existed holds the hashes of every file, which are moved into their shards
+	legacy, err := ParseRemote([]byte(`{"sequence":1,"files":{"root.md":"1","folder/LoremIpsum.md":"2"}}`))
+	assert.NoError(t, err)
+	assert.True(t, legacy.Modified())
+	assert.Equal(t, []string{"folder"}, legacy.Changed())
+
+	hash, ok := legacy.File(filepath.Join("folder", "LoremIpsum.md"))
+	assert.True(t, ok)
+	assert.Equal(t, "2", hash)
+}
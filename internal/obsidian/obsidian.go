@@ -0,0 +1,90 @@
+package obsidian
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const AppFile = "app.json"
+
+type app struct {
+	UserIgnoreFilters []string `json:"userIgnoreFilters"`
+}
+
+type Filters struct {
+	prefixes []string
+	patterns []*regexp.Regexp
+}
+
+// LoadFilters reads the excluded files setting of the config folder, where each filter is either
+// a path prefix or a regular expression wrapped in slashes.
+func LoadFilters(configPath string) (*Filters, error) {
+	path := filepath.Join(configPath, AppFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Filters{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read obsidian settings %s: %w", path, err)
+	}
+
+	var a app
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to parse obsidian settings %s: %w", path, err)
+	}
+
+	f := &Filters{}
+	for _, filter := range a.UserIgnoreFilters {
+		if len(filter) > 2 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/") {
+			re, err := regexp.Compile(filter[1 : len(filter)-1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse obsidian excluded files filter %s: %w", filter, err)
+			}
+
+			f.patterns = append(f.patterns, re)
+			continue
+		}
+
+		if filter != "" {
+			f.prefixes = append(f.prefixes, filter)
+		}
+	}
+
+	return f, nil
+}
+
+// String lists the filters one per line, in the format of the excluded files setting.
+func (f *Filters) String() string {
+	var b strings.Builder
+	for _, prefix := range f.prefixes {
+		b.WriteString(prefix + "\n")
+	}
+	for _, pattern := range f.patterns {
+		b.WriteString("/" + pattern.String() + "/\n")
+	}
+
+	return b.String()
+}
+
+func (f *Filters) Match(relativePath string) bool {
+	relativePath = filepath.ToSlash(relativePath)
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(relativePath, prefix) || relativePath+"/" == prefix {
+			return true
+		}
+	}
+
+	for _, pattern := range f.patterns {
+		if pattern.MatchString(relativePath) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,30 @@
+package obsidian
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFilters(t *testing.T) {
+	configPath := t.TempDir()
+	err := os.WriteFile(filepath.Join(configPath, AppFile), []byte(`{"userIgnoreFilters": ["Archive/", "/\\.pdf$/"]}`), 0644)
+	assert.NoError(t, err)
+
+	f, err := LoadFilters(configPath)
+	assert.NoError(t, err)
+
+	assert.True(t, f.Match("Archive"))
+	assert.True(t, f.Match(filepath.Join("Archive", "note.md")))
+	assert.True(t, f.Match(filepath.Join("folder-1", "export.pdf")))
+	assert.False(t, f.Match(filepath.Join("folder-1", "File-1.md")))
+	assert.Equal(t, "Archive/\n/\\.pdf$/\n", f.String())
+}
+
+func TestLoadFiltersWithoutSettings(t *testing.T) {
+	f, err := LoadFilters(t.TempDir())
+	assert.NoError(t, err)
+	assert.False(t, f.Match("Archive"))
+}
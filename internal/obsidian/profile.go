@@ -0,0 +1,56 @@
+package obsidian
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type Profile string
+
+const (
+	ProfileNone     Profile = "none"
+	ProfileSettings Profile = "settings"
+	ProfileFull     Profile = "full"
+)
+
+var volatileFiles = map[string]bool{
+	"workspace.json":        true,
+	"workspace-mobile.json": true,
+	"workspaces.json":       true,
+}
+
+const cacheFolder = "cache"
+
+func ParseProfile(value string) (Profile, error) {
+	switch p := Profile(value); p {
+	case ProfileNone, ProfileSettings, ProfileFull:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid config profile %s: must be one of %s, %s or %s", value, ProfileNone, ProfileSettings, ProfileFull)
+	}
+}
+
+// Volatile reports whether a path relative to the config folder holds per-device state,
+// such as the workspace layout or the cache, which changes on every use of the app.
+func Volatile(relativePath string) bool {
+	relativePath = filepath.ToSlash(relativePath)
+	if volatileFiles[relativePath] {
+		return true
+	}
+
+	return relativePath == cacheFolder || strings.HasPrefix(relativePath, cacheFolder+"/")
+}
+
+// Excludes reports whether a path relative to the config folder is left out by the profile,
+// "." being the config folder itself. An empty profile behaves like the settings profile.
+func (p Profile) Excludes(relativePath string) bool {
+	switch p {
+	case ProfileNone:
+		return true
+	case ProfileFull:
+		return false
+	default:
+		return relativePath != "." && Volatile(relativePath)
+	}
+}
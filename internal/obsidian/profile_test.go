@@ -0,0 +1,33 @@
+package obsidian
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProfile(t *testing.T) {
+	p, err := ParseProfile("settings")
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileSettings, p)
+
+	_, err = ParseProfile("workspace")
+	assert.Error(t, err)
+}
+
+func TestProfileExcludes(t *testing.T) {
+	assert.True(t, ProfileNone.Excludes("."))
+	assert.True(t, ProfileNone.Excludes("app.json"))
+
+	assert.False(t, ProfileSettings.Excludes("."))
+	assert.False(t, ProfileSettings.Excludes("app.json"))
+	assert.False(t, ProfileSettings.Excludes(filepath.Join("plugins", "workspace.json")))
+	assert.True(t, ProfileSettings.Excludes("workspace.json"))
+	assert.True(t, ProfileSettings.Excludes("workspace-mobile.json"))
+	assert.True(t, ProfileSettings.Excludes(filepath.Join("cache", "index")))
+	assert.True(t, Profile("").Excludes("workspace.json"))
+
+	assert.False(t, ProfileFull.Excludes("workspace.json"))
+	assert.False(t, ProfileFull.Excludes("cache"))
+}
@@ -0,0 +1,31 @@
+package password
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const EnvironmentVariable = "OV_PASSWORD"
+
+// Resolve returns the password given as flag, read from the password file or set in the environment, in that order.
+func Resolve(password, file string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file %s: %w", file, err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	if password, ok := os.LookupEnv(EnvironmentVariable); ok && password != "" {
+		return password, nil
+	}
+
+	return "", fmt.Errorf("password is required: use --password, --password-file or %s", EnvironmentVariable)
+}
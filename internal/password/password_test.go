@@ -0,0 +1,33 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv(EnvironmentVariable, "")
+
+	_, err := Resolve("", "")
+	assert.Error(t, err)
+
+	t.Setenv(EnvironmentVariable, "environment")
+	password, err := Resolve("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "environment", password)
+
+	file := filepath.Join(t.TempDir(), "password")
+	err = os.WriteFile(file, []byte("file\n"), 0600)
+	assert.NoError(t, err)
+
+	password, err = Resolve("", file)
+	assert.NoError(t, err)
+	assert.Equal(t, "file", password)
+
+	password, err = Resolve("flag", file)
+	assert.NoError(t, err)
+	assert.Equal(t, "flag", password)
+}
@@ -0,0 +1,49 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+)
+
+type Policy struct {
+	All    time.Duration
+	Daily  time.Duration
+	Weekly time.Duration
+}
+
+func New(all, daily, weekly int) Policy {
+	day := 24 * time.Hour
+	return Policy{All: time.Duration(all) * day, Daily: time.Duration(daily) * day, Weekly: time.Duration(weekly) * day}
+}
+
+// Keep reports which of the dates, sorted from newest to oldest, should be kept at the given time.
+// The newest date is always kept, then every date within All, the newest date of each day within Daily
+// and the newest date of each week within Weekly.
+func (p Policy) Keep(now time.Time, dates []time.Time) []bool {
+	keep := make([]bool, len(dates))
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+
+	for i, date := range dates {
+		age := now.Sub(date)
+		day := date.Local().Format(time.DateOnly)
+		year, week := date.Local().ISOWeek()
+		isoWeek := fmt.Sprintf("%d-W%02d", year, week)
+
+		switch {
+		case i == 0 || age <= p.All:
+			keep[i] = true
+		case age <= p.Daily:
+			keep[i] = !days[day]
+		case age <= p.Weekly:
+			keep[i] = !weeks[isoWeek]
+		}
+
+		if keep[i] {
+			days[day] = true
+			weeks[isoWeek] = true
+		}
+	}
+
+	return keep
+}
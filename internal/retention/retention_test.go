@@ -0,0 +1,36 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeep(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	policy := New(7, 30, 365)
+
+	dates := []time.Time{
+		now.Add(-1 * time.Hour),
+		now.AddDate(0, 0, -1),
+		now.AddDate(0, 0, -1).Add(-time.Hour),
+		now.AddDate(0, 0, -10),
+		now.AddDate(0, 0, -10).Add(-time.Hour),
+		now.AddDate(0, 0, -11),
+		now.AddDate(0, 0, -100),
+		now.AddDate(0, 0, -101),
+		now.AddDate(0, 0, -400),
+	}
+
+	keep := policy.Keep(now, dates)
+	assert.Equal(t, []bool{true, true, true, true, false, true, true, false, false}, keep)
+}
+
+func TestKeepAlwaysKeepsNewest(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	policy := New(0, 0, 0)
+
+	keep := policy.Keep(now, []time.Time{now.AddDate(-2, 0, 0), now.AddDate(-3, 0, 0)})
+	assert.Equal(t, []bool{true, false}, keep)
+}
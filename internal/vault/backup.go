@@ -0,0 +1,410 @@
+package vault
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/internal/crypto"
+	"github.com/jhandguy/obsidian-vault/internal/manifest"
+	"go.uber.org/zap"
+)
+
+// backupFolder holds the archives of the local vault taken before each pull changing it, so that it can be undone.
+const backupFolder = ".ov-backups"
+
+const (
+	backupExtension          = ".tar.gz"
+	encryptedBackupExtension = ".tar.gz.enc"
+)
+
+// backupLayout names archives to the nanosecond, so that pulls within the same second never overwrite each other's,
+// and that archives sort oldest first.
+const backupLayout = "2006-01-02T15-04-05.000000000"
+
+// backupManifest is the archive entry holding the sync manifest, which is reserved as the backup folder is never archived.
+const backupManifest = backupFolder + "/manifest.json"
+
+// Undo restores the local vault and its sync manifest from the archive taken before the last pull, moving the files
+// it replaces or does not contain into the trash, and removes the archive. As the sync manifest is restored,
+// the next push leaves the files changed by the pull as they are in the git vault, and the next pull changes them again.
+func (v *Vault) Undo(password string) error {
+	names, err := v.listBackups()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		return errors.New("no pull to undo")
+	}
+
+	name := names[len(names)-1]
+	path := filepath.Join(v.getBackupPath(), name)
+
+	var key []byte
+	if strings.HasSuffix(name, encryptedBackupExtension) {
+		if password == "" {
+			return fmt.Errorf("password required to decrypt backup %s", name)
+		}
+
+		if key, err = v.crypto.Key(password, strings.TrimSuffix(name, encryptedBackupExtension)); err != nil {
+			return fmt.Errorf("failed to decrypt backup %s: %w", path, err)
+		}
+	}
+
+	// the archive is read as a stream, so it is checked as a whole before the local vault is changed
+	err = v.readBackup(path, key, func(tr *tar.Reader) error {
+		for {
+			if _, err := tr.Next(); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	zap.S().Infof("↩️  undoing pull from backup: %s", name)
+	v.newTrash()
+
+	directories, files, err := v.list(v.localPath)
+	if err != nil {
+		return err
+	}
+
+	archived := map[string]bool{}
+	archivedDirectories := map[string]bool{}
+	var synced []byte
+	err = v.readBackup(path, key, func(tr *tar.Reader) error {
+		for {
+			header, err := tr.Next()
+			if err != nil {
+				return err
+			}
+
+			if header.Name == backupManifest {
+				if synced, err = io.ReadAll(tr); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := v.unarchive(tr, header, archived, archivedDirectories); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if archived[file] {
+			continue
+		}
+
+		if err := v.discard(filepath.Join(v.localPath, file)); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range slices.Backward(directories) {
+		dirPath := filepath.Join(v.localPath, dir)
+		if archivedDirectories[dir] {
+			continue
+		}
+
+		if entries, err := os.ReadDir(dirPath); err != nil || len(entries) > 0 {
+			continue
+		}
+
+		if err := v.removeDirectory(dirPath); err != nil {
+			return err
+		}
+	}
+
+	if synced != nil {
+		if err := os.WriteFile(v.getManifestPath(), synced, 0644); err != nil {
+			return fmt.Errorf("failed to write manifest %s: %w", v.getManifestPath(), err)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove backup %s: %w", path, err)
+	}
+
+	zap.S().Info("✅ vault undo successful")
+	return nil
+}
+
+// readBackup streams the archive at path, decrypting it with key unless nil, through fn until it returns io.EOF.
+func (v *Vault) readBackup(path string, key []byte, fn func(tr *tar.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if key != nil {
+		if r, err = v.crypto.NewReader(f, key); err != nil {
+			return fmt.Errorf("failed to decrypt backup %s: %w", path, err)
+		}
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	if err := fn(tar.NewReader(gz)); err != io.EOF {
+		return fmt.Errorf("failed to read backup %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// unarchive restores an archived file or directory into the local vault, moving the file it replaces into the trash.
+func (v *Vault) unarchive(tr *tar.Reader, header *tar.Header, archived, archivedDirectories map[string]bool) error {
+	fileName := filepath.FromSlash(header.Name)
+	if !filepath.IsLocal(fileName) {
+		return fmt.Errorf("invalid file %s", header.Name)
+	}
+
+	localFile := filepath.Join(v.localPath, fileName)
+	if header.Typeflag == tar.TypeDir {
+		archivedDirectories[fileName] = true
+		if err := os.MkdirAll(localFile, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", localFile, err)
+		}
+		return nil
+	}
+
+	archived[fileName] = true
+	content := []byte(header.Linkname)
+	if header.Typeflag != tar.TypeSymlink {
+		var err error
+		if content, err = io.ReadAll(tr); err != nil {
+			return err
+		}
+	}
+
+	metadata := crypto.Metadata{ModTime: header.ModTime, Mode: manifest.Executable(fs.FileMode(header.Mode)), Symlink: header.Typeflag == tar.TypeSymlink}
+	if info, err := os.Lstat(localFile); err == nil {
+		existing, err := v.readFile(localFile, info)
+		if err == nil && bytes.Equal(existing, content) && isSymlink(info.Mode()) == metadata.Symlink {
+			// the content is left as it is, but a pull may have changed the mode or modification time
+			if metadata.Symlink {
+				return nil
+			}
+
+			return setMetadata(localFile, metadata)
+		}
+
+		if err := v.discard(localFile); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localFile), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(localFile), err)
+	}
+
+	return writeFile(localFile, content, metadata)
+}
+
+// backup archives the local vault before a pull changes it, and rotates the previous archives.
+func (v *Vault) backup(password string) error {
+	if v.backups <= 0 {
+		return nil
+	}
+
+	name := time.Now().Format(backupLayout)
+	extension := backupExtension
+	if v.encryptBackups {
+		extension = encryptedBackupExtension
+	}
+	path := filepath.Join(v.getBackupPath(), name+extension)
+
+	if v.dryRun {
+		zap.S().Infof("would back up local vault: %s", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(v.getBackupPath(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", v.getBackupPath(), err)
+	}
+
+	// the archive is written aside first, so that an interrupted backup is never taken for one to undo
+	tmpPath := path + ".tmp"
+	size, err := v.writeBackup(tmpPath, name, password)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write backup %s: %w", path, err)
+	}
+	zap.S().Debugf("backed up local vault: %s (%dB)", path, size)
+
+	names, err := v.listBackups()
+	if err != nil {
+		return err
+	}
+
+	for len(names) > v.backups {
+		if err := os.Remove(filepath.Join(v.getBackupPath(), names[0])); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", names[0], err)
+		}
+		zap.S().Debugf("removed backup: %s", names[0])
+		names = names[1:]
+	}
+
+	return nil
+}
+
+// writeBackup streams the local vault and its sync manifest through tar, gzip and, if enabled, encryption into path,
+// returning the size of the archive.
+func (v *Vault) writeBackup(path, name, password string) (int64, error) {
+	directories, files, err := v.list(v.localPath)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write backup %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var w io.WriteCloser = f
+	if v.encryptBackups {
+		key, err := v.crypto.Key(password, name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt backup %s: %w", path, err)
+		}
+
+		if w, err = v.crypto.NewWriter(f, key); err != nil {
+			return 0, fmt.Errorf("failed to encrypt backup %s: %w", path, err)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, entry := range append(directories, files...) {
+		if err := v.archive(tw, entry); err != nil {
+			return 0, fmt.Errorf("failed to back up file %s: %w", entry, err)
+		}
+	}
+
+	if err := v.archiveManifest(tw); err != nil {
+		return 0, fmt.Errorf("failed to back up manifest: %w", err)
+	}
+
+	for _, c := range []io.Closer{tw, gz, w} {
+		if err := c.Close(); err != nil {
+			return 0, fmt.Errorf("failed to back up local vault: %w", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat backup %s: %w", path, err)
+	}
+
+	return info.Size(), nil
+}
+
+// archiveManifest archives the sync manifest, if any, so that undoing a pull also undoes the sync it recorded.
+func (v *Vault) archiveManifest(tw *tar.Writer) error {
+	data, err := os.ReadFile(v.getManifestPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: backupManifest, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+func (v *Vault) archive(tw *tar.Writer, fileName string) error {
+	localFile := filepath.Join(v.localPath, fileName)
+	info, err := v.stat(localFile)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if isSymlink(info.Mode()) {
+		if link, err = os.Readlink(localFile); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(fileName)
+	// PAX keeps the modification time to the nanosecond, so that an undone file matches its sync manifest entry
+	header.Format = tar.FormatPAX
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(localFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// listBackups returns the names of the archives of the local vault, oldest first.
+func (v *Vault) listBackups() ([]string, error) {
+	entries, err := os.ReadDir(v.getBackupPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backups %s: %w", v.getBackupPath(), err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), backupExtension) || strings.HasSuffix(entry.Name(), encryptedBackupExtension)) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+func (v *Vault) getBackupPath() string {
+	return filepath.Join(v.localPath, backupFolder)
+}
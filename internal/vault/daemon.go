@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/internal/git"
+	"go.uber.org/zap"
+)
+
+const minBackoff = time.Minute
+
+type status struct {
+	LastRun     time.Time `json:"last_run"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+	Failures    int       `json:"failures"`
+	NextRun     time.Time `json:"next_run"`
+}
+
+func (v *Vault) Daemon(ctx context.Context, password string, interval time.Duration, statusPath string) error {
+	if statusPath == "" {
+		statusPath = filepath.Join(v.gitPath, git.HiddenFolder, "obsidian-vault-status.json")
+	}
+
+	zap.S().Infof("⏰ syncing vault every %s: %s", interval, v.localPath)
+	var s status
+	for {
+		s.LastRun = time.Now()
+		wait := interval
+		if err := v.sync(password); err != nil {
+			s.Failures++
+			s.LastError = err.Error()
+			s.LastErrorAt = time.Now()
+			wait = backoff(s.Failures, interval)
+			zap.S().Errorf("❌ %v", err)
+			zap.S().Infof("⏳ retrying in %s", wait)
+		} else {
+			s.Failures = 0
+			s.LastSuccess = time.Now()
+		}
+
+		s.NextRun = time.Now().Add(wait)
+		if err := writeStatus(statusPath, s); err != nil {
+			zap.S().Errorf("❌ %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			zap.S().Info("✅ vault daemon stopped")
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (v *Vault) sync(password string) error {
+	if err := v.Pull(password, false); err != nil {
+		return err
+	}
+
+	return v.Push(password, false, DefaultDeleteLimit)
+}
+
+func backoff(failures int, interval time.Duration) time.Duration {
+	wait := minBackoff
+	for i := 1; i < failures && wait < interval; i++ {
+		wait *= 2
+	}
+
+	return min(wait, interval)
+}
+
+func writeStatus(path string, s status) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create status directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write status %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write status %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,466 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/internal/crypto"
+	"github.com/jhandguy/obsidian-vault/internal/diff"
+	"github.com/jhandguy/obsidian-vault/internal/git"
+	"go.uber.org/zap"
+)
+
+func (v *Vault) Log(w io.Writer, path, password string, stat bool) error {
+	if stat && password == "" {
+		return fmt.Errorf("password is required to compute diff stats")
+	}
+
+	fileName, err := v.getRelativePath(path)
+	if err != nil {
+		return err
+	}
+
+	commits, err := v.git.Log(v.stderr, filepath.ToSlash(fileName))
+	if err != nil {
+		return err
+	}
+
+	if len(commits) == 0 {
+		return fmt.Errorf("no history found for note: %s", fileName)
+	}
+
+	objects := make([]*git.Object, len(commits))
+	contents := make([]string, len(commits)+1)
+	for i, commit := range commits {
+		object, err := v.getObject(commit.Hash, fileName)
+		if err != nil {
+			return err
+		}
+		objects[i] = object
+
+		if password == "" || object == nil {
+			continue
+		}
+
+		data, _, err := v.readObject(*object, password)
+		if err != nil {
+			return err
+		}
+		contents[i] = string(data)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, commit := range commits {
+		size := "deleted"
+		switch {
+		case objects[i] == nil:
+		case password != "":
+			size = fmt.Sprintf("%dB", len(contents[i]))
+		default:
+			size = fmt.Sprintf("%dB encrypted", objects[i].Size)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s", shortHash(commit.Hash), commit.Date.Local().Format(time.DateTime), size)
+
+		if stat {
+			insertions, deletions := diff.Stat(diff.Compute(diff.Lines(contents[i+1]), diff.Lines(contents[i])))
+			fmt.Fprintf(tw, "\t+%d -%d", insertions, deletions)
+		}
+
+		fmt.Fprintf(tw, "\t%s\n", commit.Subject)
+	}
+
+	return tw.Flush()
+}
+
+func (v *Vault) Diff(w io.Writer, path, from, to, password string) error {
+	fileName, err := v.getRelativePath(path)
+	if err != nil {
+		return err
+	}
+
+	if from == "" {
+		from = "HEAD"
+	}
+
+	fromRev, err := v.resolveRevision(from)
+	if err != nil {
+		return err
+	}
+
+	fromObjects, err := v.getObjects(fromRev, fileName)
+	if err != nil {
+		return err
+	}
+
+	var toObjects map[string]git.Object
+	var toFiles map[string][]byte
+	if to == "" {
+		if toFiles, err = v.readLocalFiles(fileName); err != nil {
+			return err
+		}
+	} else {
+		toRev, err := v.resolveRevision(to)
+		if err != nil {
+			return err
+		}
+
+		if toObjects, err = v.getObjects(toRev, fileName); err != nil {
+			return err
+		}
+
+		for name, object := range toObjects {
+			if fromObject, ok := fromObjects[name]; ok && fromObject.Hash == object.Hash {
+				delete(fromObjects, name)
+				delete(toObjects, name)
+			}
+		}
+
+		if toFiles, err = v.readObjects(toObjects, password); err != nil {
+			return err
+		}
+	}
+
+	fromFiles, err := v.readObjects(fromObjects, password)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range fromFiles {
+		names = append(names, name)
+	}
+	for name := range toFiles {
+		if _, ok := fromFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fromData, fromOk := fromFiles[name]
+		toData, toOk := toFiles[name]
+		if fromOk && toOk && bytes.Equal(fromData, toData) {
+			continue
+		}
+
+		fromName, toName := "/dev/null", "/dev/null"
+		if fromOk {
+			fromName = "a/" + filepath.ToSlash(name)
+		}
+		if toOk {
+			toName = "b/" + filepath.ToSlash(name)
+		}
+
+		if bytes.IndexByte(fromData, 0) >= 0 || bytes.IndexByte(toData, 0) >= 0 {
+			fmt.Fprintf(w, "Binary files %s and %s differ\n", fromName, toName)
+			continue
+		}
+
+		edits := diff.Compute(diff.Lines(string(fromData)), diff.Lines(string(toData)))
+		if _, err := io.WriteString(w, diff.Unified(fromName, toName, edits, 3)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *Vault) Restore(path, at, output, password string) error {
+	v.newTrash()
+
+	fileName, err := v.getRelativePath(path)
+	if err != nil {
+		return err
+	}
+
+	outputPath := v.localPath
+	if output != "" {
+		if outputPath, err = filepath.Abs(output); err != nil {
+			return fmt.Errorf("failed to get output path: %w", err)
+		}
+	}
+
+	rev, err := v.resolveRevision(at)
+	if err != nil {
+		return err
+	}
+
+	objects, err := v.getObjects(rev, fileName)
+	if err != nil {
+		return err
+	}
+
+	if len(objects) == 0 {
+		return fmt.Errorf("no files found for %s at %s", fileName, at)
+	}
+
+	zap.S().Infof("⏪ restoring %s at %s: %s", fileName, at, outputPath)
+	if err := v.restore(objects, password, outputPath); err != nil {
+		return err
+	}
+
+	zap.S().Infof("✅ vault restore successful (%d files)", len(objects))
+	return nil
+}
+
+func (v *Vault) RestoreAll(at, output, password string) error {
+	if output == "" {
+		return fmt.Errorf("output directory is required to restore the whole vault")
+	}
+
+	outputPath, err := filepath.Abs(output)
+	if err != nil {
+		return fmt.Errorf("failed to get output path: %w", err)
+	}
+
+	entries, err := os.ReadDir(outputPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read output directory %s: %w", outputPath, err)
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("output directory is not empty: %s", outputPath)
+	}
+
+	rev, err := v.resolveRevision(at)
+	if err != nil {
+		return err
+	}
+
+	objects, err := v.getObjects(rev, ".")
+	if err != nil {
+		return err
+	}
+
+	obsidian := false
+	for name := range objects {
+		if strings.HasPrefix(name, v.config+string(filepath.Separator)) {
+			obsidian = true
+			break
+		}
+	}
+
+	if !obsidian && !v.excluded(v.config, true) {
+		return fmt.Errorf("not an obsidian vault at %s", at)
+	}
+
+	zap.S().Infof("⏪ restoring vault at %s (%s): %s", at, shortHash(rev), outputPath)
+	if err := v.restore(objects, password, outputPath); err != nil {
+		return err
+	}
+
+	zap.S().Infof("✅ vault restore successful (%d files)", len(objects))
+	return nil
+}
+
+func (v *Vault) restore(objects map[string]git.Object, password, outputPath string) error {
+	names := slices.Collect(maps.Keys(objects))
+	return v.parallel(len(names), func(i int) error {
+		return v.restoreObject(objects[names[i]], password, outputPath, names[i])
+	})
+}
+
+// resolveRevision resolves a snapshot name, a date or any git revision to a commit,
+// where a date resolves to the last commit before it.
+func (v *Vault) resolveRevision(rev string) (string, error) {
+	tags, err := v.git.Tags(v.stderr, snapshotPrefix+rev)
+	if err != nil {
+		return "", err
+	}
+
+	for _, tag := range tags {
+		if tag.Name == snapshotPrefix+rev {
+			zap.S().Debugf("resolved snapshot %s to commit %s", rev, tag.Commit)
+			return tag.Commit, nil
+		}
+	}
+
+	for _, layout := range []string{time.DateOnly, time.DateTime, time.RFC3339} {
+		date, err := time.ParseInLocation(layout, rev, time.Local)
+		if err != nil {
+			continue
+		}
+
+		commit, err := v.git.Before(v.stderr, date)
+		if err != nil {
+			return "", err
+		}
+
+		if commit == "" {
+			return "", fmt.Errorf("no commit found before %s", rev)
+		}
+
+		zap.S().Debugf("resolved %s to commit %s", rev, commit)
+		return commit, nil
+	}
+
+	return v.git.Resolve(v.stderr, rev)
+}
+
+func (v *Vault) getRelativePath(path string) (string, error) {
+	relativePath := filepath.Clean(path)
+	if filepath.IsAbs(path) {
+		var err error
+		if relativePath, err = filepath.Rel(v.localPath, path); err != nil {
+			return "", fmt.Errorf("failed to get relative path %s: %w", path, err)
+		}
+	}
+
+	if relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside of vault: %s", path)
+	}
+
+	return relativePath, nil
+}
+
+func (v *Vault) getObject(rev, fileName string) (*git.Object, error) {
+	objects, err := v.git.Tree(v.stderr, rev, filepath.ToSlash(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		if object.Path == filepath.ToSlash(fileName) {
+			return &object, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (v *Vault) getObjects(rev, fileName string) (map[string]git.Object, error) {
+	objects, err := v.git.Tree(v.stderr, rev, filepath.ToSlash(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]git.Object, len(objects))
+	for _, object := range objects {
+		if path.Base(object.Path) == directoryMarker || path.Base(object.Path) == remoteManifest {
+			continue
+		}
+
+		files[filepath.FromSlash(object.Path)] = object
+	}
+
+	return files, nil
+}
+
+func (v *Vault) readObjects(objects map[string]git.Object, password string) (map[string][]byte, error) {
+	var mutex sync.Mutex
+	files := make(map[string][]byte, len(objects))
+	names := slices.Collect(maps.Keys(objects))
+
+	err := v.parallel(len(names), func(i int) error {
+		data, _, err := v.readObject(objects[names[i]], password)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		files[names[i]] = data
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (v *Vault) readLocalFiles(fileName string) (map[string][]byte, error) {
+	if err := v.scan(vaultTypeLocal, false); err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for _, file := range v.files {
+		if fileName != "." && file != fileName && !strings.HasPrefix(file, fileName+string(filepath.Separator)) {
+			continue
+		}
+
+		localFile := filepath.Join(v.localPath, file)
+		info, err := v.stat(localFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file %s: %w", localFile, err)
+		}
+
+		data, err := v.readFile(localFile, info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", localFile, err)
+		}
+		files[file] = data
+	}
+
+	return files, nil
+}
+
+func (v *Vault) readObject(object git.Object, password string) ([]byte, crypto.Metadata, error) {
+	data, err := v.git.Blob(v.stderr, object.Hash)
+	if err != nil {
+		return nil, crypto.Metadata{}, err
+	}
+
+	fileName := filepath.FromSlash(object.Path)
+	decrypted, metadata, err := v.crypto.Decrypt(data, password, fileName)
+	if err != nil {
+		return nil, crypto.Metadata{}, fmt.Errorf("failed to decrypt object %s: %w", fileName, err)
+	}
+
+	return decrypted, metadata, nil
+}
+
+func shortHash(hash string) string {
+	return hash[:min(len(hash), 7)]
+}
+
+// restoreObject decrypts an object into the output folder, following the symlink policy as pull does,
+// and moves the file it replaces into the trash when restoring into the local vault.
+func (v *Vault) restoreObject(object git.Object, password, outputPath, name string) error {
+	path := filepath.Join(outputPath, name)
+	data, metadata, err := v.readObject(object, password)
+	if err != nil {
+		return err
+	}
+
+	if metadata.Symlink && v.symlinks == SymlinkSkip {
+		zap.S().Debugf("skipped symlink: %s", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+	}
+
+	if info, err := v.stat(path); err == nil && !info.IsDir() && outputPath == v.localPath {
+		existing, err := v.readFile(path, info)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		if !bytes.Equal(existing, data) {
+			if err := v.discard(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeFile(path, data, metadata); err != nil {
+		return err
+	}
+
+	zap.S().Debugf("restored file: %s (%dB)", path, len(data))
+	return nil
+}
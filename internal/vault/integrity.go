@@ -0,0 +1,122 @@
+package vault
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/jhandguy/obsidian-vault/internal/crypto"
+	"github.com/jhandguy/obsidian-vault/internal/git"
+	"go.uber.org/zap"
+)
+
+type failure struct {
+	object git.Object
+	err    error
+}
+
+// Verify decrypts every file of the git vault in memory, or every file of its history,
+// and lists the files that cannot be decrypted along with the reason.
+func (v *Vault) Verify(w io.Writer, password string, history bool) error {
+	revs := []string{"HEAD"}
+	if history {
+		commits, err := v.git.History(v.stderr)
+		if err != nil {
+			return err
+		}
+
+		revs = revs[:0]
+		for _, commit := range commits {
+			revs = append(revs, commit.Hash)
+		}
+	}
+
+	seen := map[git.Object]bool{}
+	var objects []git.Object
+	for _, rev := range revs {
+		tree, err := v.git.Tree(v.stderr, rev, ".")
+		if err != nil {
+			return err
+		}
+
+		for _, object := range tree {
+			if path.Base(object.Path) == directoryMarker || seen[object] {
+				continue
+			}
+
+			seen[object] = true
+			objects = append(objects, object)
+		}
+	}
+
+	if len(objects) == 0 {
+		return fmt.Errorf("no files found in git vault")
+	}
+
+	zap.S().Infof("🔍 verifying %d files", len(objects))
+
+	// every file is checked, so failures are collected instead of stopping the workers at the first one
+	var mutex sync.Mutex
+	var failures []failure
+	err := v.parallel(len(objects), func(i int) error {
+		if err := v.checkObject(objects[i], password); err != nil {
+			mutex.Lock()
+			failures = append(failures, failure{object: objects[i], err: err})
+			mutex.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failures) == 0 {
+		zap.S().Infof("✅ verified %d files", len(objects))
+		return nil
+	}
+
+	slices.SortFunc(failures, func(a, b failure) int {
+		return cmp.Or(cmp.Compare(a.object.Path, b.object.Path), cmp.Compare(a.object.Hash, b.object.Hash))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range failures {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.object.Path, shortHash(f.object.Hash), describeFailure(f.err))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%d of %d files failed verification", len(failures), len(objects))
+}
+
+func (v *Vault) checkObject(object git.Object, password string) error {
+	data, err := v.git.Blob(v.stderr, object.Hash)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = v.crypto.Decrypt(data, password, filepath.FromSlash(object.Path))
+	return err
+}
+
+func describeFailure(err error) string {
+	switch {
+	case errors.Is(err, crypto.ErrWrongPassword):
+		return "encrypted under a different password"
+	case errors.Is(err, crypto.ErrTruncated):
+		return "truncated"
+	case errors.Is(err, crypto.ErrUnknownFormat):
+		return "unknown format"
+	case errors.Is(err, crypto.ErrAuthentication):
+		return "failed authentication"
+	default:
+		return err.Error()
+	}
+}
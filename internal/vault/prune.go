@@ -0,0 +1,138 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/internal/manifest"
+	"github.com/jhandguy/obsidian-vault/internal/retention"
+	"go.uber.org/zap"
+)
+
+func (v *Vault) Prune(policy retention.Policy, dryRun bool, confirm func(kept, dropped int) bool) error {
+	v.dryRun = dryRun
+
+	zap.S().Info("📡 pulling vault from GitHub")
+	if v.dryRun {
+		zap.S().Infof("would pull git vault: %s", v.gitPath)
+	} else if err := v.pullGit(""); err != nil {
+		return err
+	}
+
+	head, err := v.git.Head(v.stderr)
+	if err != nil {
+		return err
+	}
+
+	commits, err := v.git.History(v.stderr)
+	if err != nil {
+		return err
+	}
+
+	tags, err := v.git.Tags(v.stderr, snapshotPrefix)
+	if err != nil {
+		return err
+	}
+
+	snapshots := map[string]bool{}
+	for _, tag := range tags {
+		snapshots[tag.Commit] = true
+	}
+
+	dates := make([]time.Time, len(commits))
+	for i, commit := range commits {
+		dates[i] = commit.Date
+	}
+
+	keep := policy.Keep(time.Now(), dates)
+
+	dropped := 0
+	for i, commit := range commits {
+		if snapshots[commit.Hash] {
+			keep[i] = true
+		}
+
+		if keep[i] {
+			zap.S().Debugf("keeping commit: %s %s", shortHash(commit.Hash), commit.Subject)
+			continue
+		}
+
+		dropped++
+		if v.dryRun {
+			zap.S().Infof("would drop commit: %s %s", shortHash(commit.Hash), commit.Subject)
+		} else {
+			zap.S().Debugf("dropping commit: %s %s", shortHash(commit.Hash), commit.Subject)
+		}
+	}
+
+	kept := len(commits) - dropped
+	if dropped == 0 {
+		zap.S().Infof("✅ nothing to prune (%d commits)", kept)
+		return nil
+	}
+
+	if v.dryRun {
+		zap.S().Infof("would rewrite history keeping %d of %d commits", kept, len(commits))
+		zap.S().Info("✅ vault prune dry run successful")
+		return nil
+	}
+
+	if !confirm(kept, dropped) {
+		return fmt.Errorf("vault prune aborted")
+	}
+
+	zap.S().Infof("🌱 rewriting history onto orphan branch (%d commits)", kept)
+	rewritten := map[string]string{}
+	parent := ""
+	for i := len(commits) - 1; i >= 0; i-- {
+		if !keep[i] {
+			continue
+		}
+
+		if parent, err = v.git.CommitTree(v.stderr, commits[i], parent); err != nil {
+			return err
+		}
+		rewritten[commits[i].Hash] = parent
+	}
+
+	if err := v.git.Reset(v.stdout, v.stderr, parent); err != nil {
+		return err
+	}
+
+	zap.S().Info("🚀 force pushing vault to GitHub")
+	if err := v.git.ForcePush(v.stdout, v.stderr, head); err != nil {
+		if err := v.git.Reset(v.stdout, v.stderr, head); err != nil {
+			zap.S().Errorf("failed to restore git branch to %s: %v", head, err)
+		}
+		return err
+	}
+
+	for _, tag := range tags {
+		commit, ok := rewritten[tag.Commit]
+		if !ok {
+			continue
+		}
+
+		if err := v.git.Tag(v.stdout, v.stderr, tag.Name, commit, tag.Message, true); err != nil {
+			return err
+		}
+
+		if err := v.git.PushTag(v.stdout, v.stderr, tag.Name, true); err != nil {
+			return err
+		}
+	}
+
+	m, err := manifest.Load(v.getManifestPath())
+	if err != nil {
+		return err
+	}
+
+	m.Commit = rewritten[m.Commit]
+	if err := m.Save(v.getManifestPath()); err != nil {
+		return err
+	}
+
+	zap.S().Warn("⚠️  other devices must clone the git vault again")
+	zap.S().Infof("✅ vault prune successful (%d commits dropped)", dropped)
+	return nil
+}
@@ -0,0 +1,390 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/internal/crypto"
+	"github.com/jhandguy/obsidian-vault/internal/git"
+	"github.com/jhandguy/obsidian-vault/internal/manifest"
+	"go.uber.org/zap"
+)
+
+// remoteManifest is the encrypted manifest shared by all devices, whose root is kept at the root of the git vault
+// and whose shards are kept in the top-level directories.
+const remoteManifest = ".ovmanifest"
+
+func (v *Vault) loadRemote(password string) error {
+	r, err := v.readRemote(v.readGitFile, password)
+	if err != nil {
+		return err
+	}
+
+	v.remote = r
+	return nil
+}
+
+// readRemote decrypts the root of a remote manifest read with read, which is empty when missing, and the shards it records,
+// refusing a shard other than the one the root was saved with.
+func (v *Vault) readRemote(read func(fileName string) ([]byte, error), password string) (*manifest.Remote, error) {
+	data, err := read(remoteManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest.NewRemote(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", remoteManifest, err)
+	}
+
+	decrypted, _, err := v.crypto.Decrypt(data, password, remoteManifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file %s: %w", remoteManifest, err)
+	}
+
+	r, err := manifest.ParseRemote(decrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := slices.Sorted(maps.Keys(r.Shards))
+	err = v.parallel(len(shards), func(i int) error {
+		fileName := filepath.Join(shards[i], remoteManifest)
+		data, err := read(fileName)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", fileName, err)
+		}
+
+		if manifest.Hash(data) != r.Shards[shards[i]] {
+			return fmt.Errorf("file %s does not match remote manifest, git vault may have been rolled back", fileName)
+		}
+
+		decrypted, _, err := v.crypto.Decrypt(data, password, fileName)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt file %s: %w", fileName, err)
+		}
+
+		return r.ParseShard(shards[i], decrypted)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// saveRemote encrypts the shards of the remote manifest changed since it was read or missing from the git vault,
+// then its root recording their hashes, so that a push only rewrites the shards of the directories it changed.
+func (v *Vault) saveRemote(password string) error {
+	if _, err := os.Stat(filepath.Join(v.gitPath, remoteManifest)); err == nil && !v.remote.Modified() {
+		return nil
+	}
+
+	shards := v.remote.Changed()
+	for shard := range v.remote.Shards {
+		if _, err := os.Stat(filepath.Join(v.gitPath, shard, remoteManifest)); err != nil && !slices.Contains(shards, shard) {
+			shards = append(shards, shard)
+		}
+	}
+
+	err := v.parallel(len(shards), func(i int) error {
+		return v.saveShard(shards[i], password)
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := v.remote.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = v.sealRemote(remoteManifest, data, password)
+	return err
+}
+
+// saveShard encrypts a shard of the remote manifest into its directory, or removes it once the directory has no file left.
+func (v *Vault) saveShard(shard, password string) error {
+	fileName := filepath.Join(shard, remoteManifest)
+	data, err := v.remote.MarshalShard(shard)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		path := filepath.Join(v.gitPath, fileName)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove file %s: %w", path, err)
+		}
+
+		v.remote.SetShard(shard, "")
+		return nil
+	}
+
+	encrypted, err := v.sealRemote(fileName, data, password)
+	if err != nil {
+		return err
+	}
+
+	v.remote.SetShard(shard, manifest.Hash(encrypted))
+	return nil
+}
+
+// sealRemote encrypts a file of the remote manifest into the git vault, checking that it decrypts back,
+// and returns its ciphertext.
+func (v *Vault) sealRemote(fileName string, data []byte, password string) ([]byte, error) {
+	path := filepath.Join(v.gitPath, fileName)
+	key, err := v.crypto.Key(password, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt file %s: %w", path, err)
+	}
+
+	encrypted, err := v.crypto.Seal(data, crypto.Metadata{}, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt file %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, encrypted, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+
+	if _, err := v.verify(path, key, manifest.Hash(data)); err != nil {
+		return nil, err
+	}
+
+	zap.S().Debugf("encrypted file: %s (%dB)", path, len(encrypted))
+	return encrypted, nil
+}
+
+// resolveConflicts completes a pull of diverged git vaults that failed on conflicts, merging the remote manifests
+// changed on both sides, which git cannot do as they are encrypted. Any other conflict aborts the merge.
+func (v *Vault) resolveConflicts(password string, pullErr error) error {
+	conflicts, err := v.git.Conflicts(v.stderr)
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		return pullErr
+	}
+
+	var files []string
+	for _, conflict := range conflicts {
+		if path.Base(conflict.Path) != remoteManifest && !slices.Contains(files, conflict.Path) {
+			files = append(files, conflict.Path)
+		}
+	}
+
+	if len(files) > 0 || password == "" {
+		if err := v.git.AbortMerge(v.stdout, v.stderr); err != nil {
+			return err
+		}
+
+		if len(files) > 0 {
+			return fmt.Errorf("failed to merge git vault, files changed on both sides: %s", strings.Join(files, ", "))
+		}
+
+		return fmt.Errorf("failed to merge git vault, run pull first: %w", pullErr)
+	}
+
+	zap.S().Infof("🔀 merging remote manifest: %s", remoteManifest)
+	base, err := v.git.MergeBase(v.stderr, git.Head, git.MergeHead)
+	if err != nil {
+		return err
+	}
+
+	// the shards changed on a single side are merged by git, so every side is read as committed
+	sides := make([]*manifest.Remote, 3)
+	for i, rev := range []string{base, git.Head, git.MergeHead} {
+		if rev == "" {
+			sides[i] = manifest.NewRemote()
+			continue
+		}
+
+		read := func(fileName string) ([]byte, error) {
+			return v.readGitFileAt(rev, fileName)
+		}
+		if sides[i], err = v.readRemote(read, password); err != nil {
+			return err
+		}
+	}
+
+	v.remote = manifest.MergeRemote(sides[0], sides[1], sides[2])
+	if err := v.saveRemote(password); err != nil {
+		return err
+	}
+
+	if err := v.git.Add(v.stdout, v.stderr); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("[%s] obsidian-vault merge", time.Now().Format(time.DateTime))
+	return v.git.Commit(v.stdout, v.stderr, msg)
+}
+
+// checkSequence refuses a remote manifest older than the last one synced, as the whole git vault was then rolled back.
+func (v *Vault) checkSequence() error {
+	if v.remote.Sequence < v.manifest.Sequence {
+		return fmt.Errorf("remote manifest sequence %d is older than last synced sequence %d, git vault may have been rolled back", v.remote.Sequence, v.manifest.Sequence)
+	}
+
+	return nil
+}
+
+// checkRemote compares the files of the git vault with the remote manifest, returning the files missing from the git vault,
+// not matching their recorded hash, not recorded at all or deleted, which were removed or rolled back outside of ov.
+func (v *Vault) checkRemote(files []string) (map[string]bool, error) {
+	if err := v.checkSequence(); err != nil {
+		return nil, err
+	}
+
+	untrusted := map[string]bool{}
+	if v.remote.Sequence == 0 {
+		return untrusted, nil
+	}
+
+	var recorded []string
+	for _, name := range slices.Sorted(maps.Keys(v.remote.Files)) {
+		if file := filepath.FromSlash(name); !v.excluded(file, false) {
+			recorded = append(recorded, file)
+		}
+	}
+
+	hashes, err := v.hashGitFiles(recorded)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range recorded {
+		hash, ok := hashes[file]
+		if !ok {
+			zap.S().Warnf("skipped file missing from git vault: %s", file)
+			untrusted[file] = true
+			continue
+		}
+
+		if hash != v.remote.Files[filepath.ToSlash(file)] {
+			zap.S().Warnf("skipped file not matching remote manifest, it may have been rolled back: %s", file)
+			untrusted[file] = true
+		}
+	}
+
+	for _, file := range files {
+		if _, ok := v.remote.Tombstone(file); ok {
+			zap.S().Warnf("skipped file deleted remotely but found in git vault: %s", file)
+			untrusted[file] = true
+			continue
+		}
+
+		if _, ok := v.remote.File(file); !ok {
+			zap.S().Warnf("skipped file missing from remote manifest: %s", file)
+			untrusted[file] = true
+		}
+	}
+
+	return untrusted, nil
+}
+
+// hashGitFiles hashes the encrypted files of the git vault, or of the fetched upstream commit during a dry run pull,
+// where its blobs are read by a single git process. Files missing from the git vault are left out.
+func (v *Vault) hashGitFiles(files []string) (map[string]string, error) {
+	hashes := map[string]string{}
+	if v.upstream == "" {
+		for _, file := range files {
+			gitFile := filepath.Join(v.gitPath, file)
+			data, err := os.ReadFile(gitFile)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file %s: %w", gitFile, err)
+			}
+
+			hashes[file] = manifest.Hash(data)
+		}
+
+		return hashes, nil
+	}
+
+	objects, err := v.git.Tree(v.stderr, v.upstream, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, file := range files {
+		wanted[file] = true
+	}
+
+	blobs := map[string][]string{}
+	var ids []string
+	for _, object := range objects {
+		file := filepath.FromSlash(object.Path)
+		if !wanted[file] {
+			continue
+		}
+
+		if _, ok := blobs[object.Hash]; !ok {
+			ids = append(ids, object.Hash)
+		}
+		blobs[object.Hash] = append(blobs[object.Hash], file)
+	}
+
+	err = v.git.Blobs(v.stderr, ids, func(id string, data []byte) error {
+		hash := manifest.Hash(data)
+		for _, file := range blobs[id] {
+			hashes[file] = hash
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+func (v *Vault) bury(fileName string) error {
+	device, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get device name: %w", err)
+	}
+
+	v.remote.Bury(fileName, device, time.Now())
+	return nil
+}
+
+// edited reports whether a local file changed since it was last synced, or was never synced at all.
+func (v *Vault) edited(fileName string, info fs.FileInfo) (bool, error) {
+	entry, ok := v.manifest.Get(fileName)
+	if !ok {
+		return true, nil
+	}
+
+	if entry.Unchanged(info) {
+		return false, nil
+	}
+
+	localFile := filepath.Join(v.localPath, fileName)
+	data, err := v.readFile(localFile, info)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file %s: %w", localFile, err)
+	}
+
+	return manifest.Hash(data) != entry.Hash, nil
+}
+
+func describeDeletion(tombstone manifest.Tombstone, ok bool) string {
+	if !ok {
+		return "remotely"
+	}
+
+	return fmt.Sprintf("on %s at %s", tombstone.Device, tombstone.Time.Local().Format(time.DateTime))
+}
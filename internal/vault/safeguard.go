@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// DeleteLimit caps the files a push may remove from the git vault, as a count and as a fraction of its files.
+// Zero values disable the corresponding limit.
+type DeleteLimit struct {
+	Count    int
+	Fraction float64
+}
+
+var DefaultDeleteLimit = DeleteLimit{Count: 100, Fraction: 0.5}
+
+const maxListedRemovals = 20
+
+func (l DeleteLimit) exceeded(removals, total int) bool {
+	if l.Count > 0 && removals > l.Count {
+		return true
+	}
+
+	return l.Fraction > 0 && total > 0 && float64(removals) > l.Fraction*float64(total)
+}
+
+// checkRemovals refuses a push removing more files from the git vault than the limit allows,
+// before any of them is removed.
+func (v *Vault) checkRemovals(limit DeleteLimit) error {
+	_, gitFiles, err := v.list(v.gitPath)
+	if err != nil {
+		return err
+	}
+
+	localFiles := toSet(v.files)
+	var removals []string
+	for _, file := range gitFiles {
+		if localFiles[file] {
+			continue
+		}
+
+		if _, ok := v.manifest.Get(file); !ok && v.manifest.Len() > 0 {
+			continue
+		}
+
+		removals = append(removals, file)
+	}
+
+	if !limit.exceeded(len(removals), len(gitFiles)) {
+		return nil
+	}
+
+	zap.S().Warnf("⚠️  push would remove %d of %d files from git vault:", len(removals), len(gitFiles))
+	for i, file := range removals {
+		if i == maxListedRemovals {
+			zap.S().Warnf("... and %d more", len(removals)-maxListedRemovals)
+			break
+		}
+
+		zap.S().Warnf("- %s", file)
+	}
+
+	return fmt.Errorf("refusing to remove %d of %d files from git vault, use --allow-mass-delete to override", len(removals), len(gitFiles))
+}
@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const snapshotPrefix = "snapshot/"
+
+var snapshotRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func (v *Vault) CreateSnapshot(name, at string) error {
+	if !snapshotRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name: %s", name)
+	}
+
+	rev, err := v.resolveRevision(at)
+	if err != nil {
+		return err
+	}
+
+	objects, err := v.getObjects(rev, ".")
+	if err != nil {
+		return err
+	}
+
+	device, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get device name: %w", err)
+	}
+
+	zap.S().Infof("📸 creating snapshot %s at %s", name, at)
+	msg := fmt.Sprintf("obsidian-vault snapshot %s\n\nfiles: %d\ndevice: %s\ntimestamp: %s", name, len(objects), device, time.Now().Format(time.RFC3339))
+	if err := v.git.Tag(v.stdout, v.stderr, snapshotPrefix+name, rev, msg, false); err != nil {
+		return err
+	}
+
+	zap.S().Info("🚀 pushing snapshot to GitHub")
+	if err := v.git.PushTag(v.stdout, v.stderr, snapshotPrefix+name, false); err != nil {
+		return err
+	}
+
+	zap.S().Info("✅ snapshot creation successful")
+	return nil
+}
+
+func (v *Vault) ListSnapshots(w io.Writer) error {
+	tags, err := v.git.Tags(v.stderr, snapshotPrefix)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, tag := range tags {
+		metadata := parseSnapshotMetadata(tag.Message)
+
+		timestamp := tag.Date
+		if date, err := time.Parse(time.RFC3339, metadata["timestamp"]); err == nil {
+			timestamp = date
+		}
+
+		name := strings.TrimPrefix(tag.Name, snapshotPrefix)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s files\t%s\n", name, shortHash(tag.Commit), timestamp.Local().Format(time.DateTime), metadata["files"], metadata["device"])
+	}
+
+	return tw.Flush()
+}
+
+func (v *Vault) RestoreSnapshot(name, output, password string) error {
+	return v.RestoreAll(snapshotPrefix+name, output, password)
+}
+
+func parseSnapshotMetadata(msg string) map[string]string {
+	metadata := map[string]string{}
+	for _, line := range strings.Split(msg, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if ok {
+			metadata[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return metadata
+}
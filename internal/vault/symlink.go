@@ -0,0 +1,51 @@
+package vault
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+type SymlinkPolicy string
+
+const (
+	SymlinkSkip   SymlinkPolicy = "skip"
+	SymlinkStore  SymlinkPolicy = "store"
+	SymlinkFollow SymlinkPolicy = "follow"
+)
+
+func ParseSymlinkPolicy(value string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(value); p {
+	case SymlinkSkip, SymlinkStore, SymlinkFollow:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid symlink policy %s: must be one of %s, %s or %s", value, SymlinkSkip, SymlinkStore, SymlinkFollow)
+	}
+}
+
+func isSymlink(mode fs.FileMode) bool {
+	return mode&fs.ModeSymlink != 0
+}
+
+// stat returns the info of a local file, or of the link itself unless symlinks are followed.
+func (v *Vault) stat(path string) (fs.FileInfo, error) {
+	if v.symlinks == SymlinkFollow {
+		return os.Stat(path)
+	}
+
+	return os.Lstat(path)
+}
+
+// readFile returns the content of a local file, or the target of a stored symlink.
+func (v *Vault) readFile(path string, info fs.FileInfo) ([]byte, error) {
+	if isSymlink(info.Mode()) {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(target), nil
+	}
+
+	return os.ReadFile(path)
+}
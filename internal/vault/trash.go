@@ -0,0 +1,256 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// trashFolder holds the local files removed or overwritten by clean and pull, in a timestamped folder per run.
+const trashFolder = ".ov-trash"
+
+const trashLayout = "2006-01-02T15-04-05"
+
+func (v *Vault) ListTrash(w io.Writer, name string) error {
+	if name != "" {
+		files, err := v.listTrashFiles(name)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, file := range files {
+			fmt.Fprintf(tw, "%s\t%dB\n", file.path, file.size)
+		}
+
+		return tw.Flush()
+	}
+
+	names, err := v.listTrashes()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		files, err := v.listTrashFiles(name)
+		if err != nil {
+			return err
+		}
+
+		var size int64
+		for _, file := range files {
+			size += file.size
+		}
+
+		fmt.Fprintf(tw, "%s\t%d files\t%dB\n", name, len(files), size)
+	}
+
+	return tw.Flush()
+}
+
+func (v *Vault) RestoreTrash(name, path string) error {
+	files, err := v.listTrashFiles(name)
+	if err != nil {
+		return err
+	}
+
+	relativePath, err := v.getRelativePath(path)
+	if err != nil {
+		return err
+	}
+
+	v.newTrash()
+	zap.S().Infof("♻️  restoring trash %s: %s", name, relativePath)
+
+	restored := 0
+	for _, file := range files {
+		if relativePath != "." && file.path != relativePath && !strings.HasPrefix(file.path, relativePath+string(filepath.Separator)) {
+			continue
+		}
+
+		localFile := filepath.Join(v.localPath, file.path)
+		if _, err := os.Lstat(localFile); err == nil {
+			if err := v.discard(localFile); err != nil {
+				return err
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(localFile), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(localFile), err)
+		}
+
+		if err := os.Rename(filepath.Join(v.getTrashPath(), name, file.path), localFile); err != nil {
+			return fmt.Errorf("failed to restore file %s: %w", localFile, err)
+		}
+
+		zap.S().Debugf("restored file: %s", localFile)
+		restored++
+	}
+
+	if restored == 0 {
+		return fmt.Errorf("no file to restore in trash %s: %s", name, relativePath)
+	}
+
+	if err := v.removeEmptyTrash(name); err != nil {
+		return err
+	}
+
+	zap.S().Infof("✅ trash restore successful: %d files", restored)
+	return nil
+}
+
+func (v *Vault) EmptyTrash(name string) error {
+	names := []string{name}
+	if name == "" {
+		var err error
+		if names, err = v.listTrashes(); err != nil {
+			return err
+		}
+	} else if _, err := v.listTrashFiles(name); err != nil {
+		return err
+	}
+
+	zap.S().Info("🗑  emptying trash")
+	for _, name := range names {
+		if err := os.RemoveAll(filepath.Join(v.getTrashPath(), name)); err != nil {
+			return fmt.Errorf("failed to remove trash %s: %w", name, err)
+		}
+
+		zap.S().Debugf("removed trash: %s", name)
+	}
+
+	if entries, err := os.ReadDir(v.getTrashPath()); err == nil && len(entries) == 0 {
+		if err := os.Remove(v.getTrashPath()); err != nil {
+			return fmt.Errorf("failed to remove trash %s: %w", v.getTrashPath(), err)
+		}
+	}
+
+	zap.S().Info("✅ trash empty successful")
+	return nil
+}
+
+// newTrash names the trash folder of the current run, which is only created when a file is discarded.
+func (v *Vault) newTrash() {
+	name := time.Now().Format(trashLayout)
+	v.trashName = name
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(v.getTrashPath(), v.trashName)); errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+
+		v.trashName = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// discard moves a local file into the trash folder of the current run instead of removing it.
+func (v *Vault) discard(path string) error {
+	if v.dryRun {
+		zap.S().Infof("would move file to trash: %s", path)
+		return nil
+	}
+
+	relativePath, err := filepath.Rel(v.localPath, path)
+	if err != nil {
+		return err
+	}
+
+	trashFile := filepath.Join(v.getTrashPath(), v.trashName, relativePath)
+	if err := os.MkdirAll(filepath.Dir(trashFile), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(trashFile), err)
+	}
+
+	if err := os.Rename(path, trashFile); err != nil {
+		return fmt.Errorf("failed to move file %s to trash: %w", path, err)
+	}
+
+	zap.S().Debugf("moved file to trash: %s", trashFile)
+	return nil
+}
+
+type trashFile struct {
+	path string
+	size int64
+}
+
+func (v *Vault) listTrashes() ([]string, error) {
+	entries, err := os.ReadDir(v.getTrashPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash %s: %w", v.getTrashPath(), err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+func (v *Vault) listTrashFiles(name string) ([]trashFile, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid trash name: %s", name)
+	}
+
+	path := filepath.Join(v.getTrashPath(), name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to find trash %s: %w", name, err)
+	}
+
+	var files []trashFile
+	fn := func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, trashFile{path: relativePath, size: info.Size()})
+		return nil
+	}
+
+	if err := filepath.WalkDir(path, fn); err != nil {
+		return nil, fmt.Errorf("failed to read trash %s: %w", name, err)
+	}
+
+	return files, nil
+}
+
+func (v *Vault) removeEmptyTrash(name string) error {
+	path := filepath.Join(v.getTrashPath(), name)
+	files, err := v.listTrashFiles(name)
+	if err != nil || len(files) > 0 {
+		return err
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove trash %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (v *Vault) getTrashPath() string {
+	return filepath.Join(v.localPath, trashFolder)
+}
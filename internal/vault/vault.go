@@ -1,33 +1,59 @@
 package vault
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jhandguy/obsidian-vault/internal/crypto"
 	"github.com/jhandguy/obsidian-vault/internal/gh"
 	"github.com/jhandguy/obsidian-vault/internal/git"
+	"github.com/jhandguy/obsidian-vault/internal/ignore"
+	"github.com/jhandguy/obsidian-vault/internal/manifest"
+	"github.com/jhandguy/obsidian-vault/internal/obsidian"
 	"go.uber.org/zap"
 )
 
 type Vault struct {
-	config      string
-	directories []string
-	files       []string
-	localPath   string
-	gitPath     string
-	gh          *gh.GitHub
-	git         *git.Git
-	crypto      *crypto.Crypto
-	stdout      io.Writer
-	stderr      io.Writer
+	config         string
+	directories    []string
+	files          []string
+	localPath      string
+	gitPath        string
+	gh             *gh.GitHub
+	git            *git.Git
+	crypto         *crypto.Crypto
+	manifest       *manifest.Manifest
+	remote         *manifest.Remote
+	ignore         *ignore.Matcher
+	obsidian       *obsidian.Filters
+	profile        obsidian.Profile
+	only           []string
+	symlinks       SymlinkPolicy
+	backups        int
+	encryptBackups bool
+	stdout         io.Writer
+	stderr         io.Writer
+	dryRun         bool
+	upstream       string
+	trashName      string
+	workers        chan struct{}
 }
 
+// directoryMarker is kept in empty directories of the git vault, as git only tracks files.
+const directoryMarker = ".ovdir"
+
+var commitRegexp = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
 type vaultType string
 
 const (
@@ -35,7 +61,16 @@ const (
 	vaultTypeGit   vaultType = "git"
 )
 
-func New(path, config string) (*Vault, error) {
+type Options struct {
+	ObsidianExcludes bool
+	ConfigProfile    obsidian.Profile
+	Only             []string
+	Symlinks         SymlinkPolicy
+	Backups          int
+	EncryptBackups   bool
+}
+
+func New(path, config string, options Options) (*Vault, error) {
 	localPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get local vault path: %w", err)
@@ -45,6 +80,33 @@ func New(path, config string) (*Vault, error) {
 	repoName := filepath.Base(localPath)
 	shell := getShell()
 
+	matcher, err := ignore.Load(filepath.Join(localPath, ignore.FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var only []string
+	for _, folder := range options.Only {
+		folder = strings.Trim(filepath.Clean(filepath.FromSlash(folder)), string(filepath.Separator))
+		if folder == "" || folder == "." || folder == ".." || strings.HasPrefix(folder, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("invalid selected folder: %s", folder)
+		}
+
+		only = append(only, folder)
+	}
+
+	if options.Symlinks == "" {
+		options.Symlinks = SymlinkStore
+	}
+
+	filters := &obsidian.Filters{}
+	if options.ObsidianExcludes {
+		filters, err = obsidian.LoadFilters(filepath.Join(localPath, config))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	zap.S().Debugf("local vault path: %s", localPath)
 	zap.S().Debugf("git vault path: %s", gitPath)
 
@@ -55,18 +117,31 @@ func New(path, config string) (*Vault, error) {
 	}
 
 	return &Vault{
-		config:    config,
-		localPath: localPath,
-		gitPath:   gitPath,
-		gh:        gh.New(shell, gitPath, repoName),
-		git:       git.New(shell, gitPath),
-		crypto:    crypto.New(),
-		stdout:    stdout,
-		stderr:    stderr,
+		config:         config,
+		localPath:      localPath,
+		gitPath:        gitPath,
+		gh:             gh.New(shell, gitPath, repoName),
+		git:            git.New(shell, gitPath),
+		crypto:         crypto.New(),
+		manifest:       manifest.New(),
+		remote:         manifest.NewRemote(),
+		ignore:         matcher,
+		obsidian:       filters,
+		profile:        options.ConfigProfile,
+		only:           only,
+		symlinks:       options.Symlinks,
+		backups:        options.Backups,
+		encryptBackups: options.EncryptBackups,
+		stdout:         stdout,
+		stderr:         stderr,
+		workers:        make(chan struct{}, runtime.NumCPU()),
 	}, nil
 }
 
-func (v *Vault) Clean(remove bool) error {
+func (v *Vault) Clean(remove, dryRun bool) error {
+	v.dryRun = dryRun
+	v.newTrash()
+
 	zap.S().Info("🧹 cleaning local vault")
 	if err := v.scan(vaultTypeLocal, false); err != nil {
 		return err
@@ -75,13 +150,35 @@ func (v *Vault) Clean(remove bool) error {
 		return err
 	}
 
+	if _, err := os.Stat(v.getManifestPath()); err == nil {
+		if err := v.removeFile(v.getManifestPath()); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(v.getBackupPath()); err == nil {
+		zap.S().Info("🗑  removing local vault backups")
+		if v.dryRun {
+			zap.S().Infof("would remove local vault backups: %s", v.getBackupPath())
+		} else if err := os.RemoveAll(v.getBackupPath()); err != nil {
+			return fmt.Errorf("failed to remove local vault backups %s: %w", v.getBackupPath(), err)
+		}
+	}
+
 	if remove {
 		zap.S().Info("🗑  removing git vault")
-		if err := os.RemoveAll(v.gitPath); err != nil {
+		if v.dryRun {
+			zap.S().Infof("would remove git vault: %s", v.gitPath)
+		} else if err := os.RemoveAll(v.gitPath); err != nil {
 			return err
 		}
 	}
 
+	if v.dryRun {
+		zap.S().Info("✅ vault clean dry run successful")
+		return nil
+	}
+
 	zap.S().Info("✅ vault clean successful")
 	return nil
 }
@@ -103,9 +200,13 @@ func (v *Vault) Clone(create bool) error {
 	return nil
 }
 
-func (v *Vault) Pull(password string) error {
+func (v *Vault) Pull(password string, dryRun bool) error {
+	v.dryRun = dryRun
+	v.newTrash()
+
 	zap.S().Info("📡 pulling vault from GitHub")
-	if err := v.git.Pull(v.stdout, v.stderr); err != nil {
+	defer func() { v.upstream = "" }()
+	if err := v.pullGit(password); err != nil {
 		return err
 	}
 
@@ -113,12 +214,71 @@ func (v *Vault) Pull(password string) error {
 		return err
 	}
 
-	if err := v.clean(vaultTypeLocal, true); err != nil {
+	if err := v.loadRemote(password); err != nil {
 		return err
 	}
 
+	m, err := manifest.Load(v.getManifestPath())
+	if err != nil {
+		return err
+	}
+	v.manifest = m
+
+	untrusted, err := v.checkRemote(v.files)
+	if err != nil {
+		return err
+	}
+
+	head := v.upstream
+	if head == "" {
+		if head, err = v.git.Head(v.stderr); err != nil {
+			return err
+		}
+	}
+
+	if !isCommit(v.manifest.Commit) || v.manifest.Commit != head {
+		zap.S().Infof("💾 backing up vault: %s", v.localPath)
+		if err := v.backup(password); err != nil {
+			return err
+		}
+	}
+
+	files := v.files
+	if isCommit(v.manifest.Commit) && isCommit(head) {
+		zap.S().Infof("🔄 updating vault: %s", v.localPath)
+		if files, err = v.updateLocalVault(head, untrusted); err != nil {
+			return err
+		}
+	} else {
+		if err := v.clean(vaultTypeLocal, true); err != nil {
+			return err
+		}
+		v.manifest = manifest.New()
+	}
+
+	// untrusted files are kept as they are locally and forgotten by the manifest, so that the next push encrypts them again
+	files = slices.DeleteFunc(slices.Clone(files), func(file string) bool { return untrusted[file] })
+	for file := range untrusted {
+		v.manifest.Delete(file)
+	}
+
 	zap.S().Infof("🔑 decrypting vault: %s", v.gitPath)
-	if err := v.decrypt(password); err != nil {
+	if err := v.decrypt(files, password); err != nil {
+		return err
+	}
+
+	if v.dryRun {
+		zap.S().Info("✅ vault sync dry run successful")
+		return nil
+	}
+
+	if isCommit(head) {
+		v.manifest.Commit = head
+	}
+	v.manifest.Sequence = v.remote.Sequence
+	v.manifest.Selection = v.selection()
+
+	if err := v.manifest.Save(v.getManifestPath()); err != nil {
 		return err
 	}
 
@@ -126,30 +286,169 @@ func (v *Vault) Pull(password string) error {
 	return nil
 }
 
-func (v *Vault) Push(password string) error {
+// pullGit pulls the git vault, after checking that its history was not rewritten by a prune on another device,
+// which git would otherwise refuse to merge with a bare exit status. A dry run only fetches the git vault
+// and reads the upstream commit in place of its working tree. Without a password, conflicts are not resolved.
+func (v *Vault) pullGit(password string) error {
+	if err := v.git.Fetch(v.stdout, v.stderr); err != nil {
+		return err
+	}
+
+	related, err := v.git.Related(v.stderr, "HEAD", git.Upstream)
+	if err != nil {
+		return err
+	}
+
+	if !related {
+		return fmt.Errorf("git vault history was pruned on another device, remove %s and run clone again", v.gitPath)
+	}
+
+	if v.dryRun {
+		zap.S().Infof("would pull git vault: %s", v.gitPath)
+		upstream, err := v.git.Resolve(v.stderr, git.Upstream)
+		if err != nil {
+			return err
+		}
+
+		v.upstream = upstream
+		return nil
+	}
+
+	if err := v.git.Pull(v.stdout, v.stderr); err != nil {
+		return v.resolveConflicts(password, err)
+	}
+
+	return nil
+}
+
+func (v *Vault) Push(password string, dryRun bool, limit DeleteLimit) error {
+	v.dryRun = dryRun
+
+	conflicts, err := v.git.Conflicts(v.stderr)
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("git vault has unresolved conflicts, run pull first: %s", v.gitPath)
+	}
+
 	if err := v.scan(vaultTypeLocal, true); err != nil {
 		return err
 	}
 
-	if err := v.clean(vaultTypeGit, true); err != nil {
+	m, err := manifest.Load(v.getManifestPath())
+	if err != nil {
+		return err
+	}
+	v.manifest = m
+
+	if err := v.loadRemote(password); err != nil {
+		return err
+	}
+
+	_, gitFiles, err := v.list(v.gitPath)
+	if err != nil {
+		return err
+	}
+
+	untrusted, err := v.checkRemote(gitFiles)
+	if err != nil {
+		return err
+	}
+
+	// untrusted files are forgotten by both manifests, so that they are encrypted again if the local vault has them
+	synced := v.manifest.Len() > 0
+	for file := range untrusted {
+		v.manifest.Delete(file)
+		v.remote.Forget(file)
+	}
+
+	if err := v.checkRemovals(limit); err != nil {
+		return err
+	}
+
+	files := v.files
+	if !synced {
+		if err := v.clean(vaultTypeGit, true); err != nil {
+			return err
+		}
+
+		for _, file := range gitFiles {
+			v.remote.Forget(file)
+		}
+	} else {
+		zap.S().Infof("🔄 updating vault: %s", v.gitPath)
+		if files, err = v.updateGitVault(); err != nil {
+			return err
+		}
+	}
+
+	if err := v.markDirectories(); err != nil {
 		return err
 	}
 
 	zap.S().Infof("🔒 encrypting vault: %s", v.localPath)
-	if err := v.encrypt(password); err != nil {
+	if err := v.encrypt(files, password); err != nil {
 		return err
 	}
 
 	zap.S().Info("🚀 pushing vault to GitHub")
+	msg := fmt.Sprintf("[%s] obsidian-vault backup", time.Now().Format(time.DateTime))
+	if v.dryRun {
+		zap.S().Infof("would commit changes: %s", msg)
+		zap.S().Infof("would push git vault: %s", v.gitPath)
+		zap.S().Info("✅ vault backup dry run successful")
+		return nil
+	}
+
+	v.remote.Advance()
+	if err := v.saveRemote(password); err != nil {
+		return err
+	}
+	v.manifest.Sequence = v.remote.Sequence
+	v.manifest.Selection = v.selection()
+
+	if err := v.manifest.Save(v.getManifestPath()); err != nil {
+		return err
+	}
+
+	var previous string
+	if isCommit(v.manifest.Commit) {
+		if previous, err = v.git.Head(v.stderr); err != nil {
+			return err
+		}
+	}
+
 	if err := v.git.Add(v.stdout, v.stderr); err != nil {
 		return err
 	}
 
-	msg := fmt.Sprintf("[%s] obsidian-vault backup", time.Now().Format(time.DateTime))
-	if err := v.git.Commit(v.stdout, v.stderr, msg); err != nil {
+	changes, err := v.git.HasChanges(v.stderr)
+	if err != nil {
+		return err
+	}
+
+	if !changes {
+		zap.S().Info("💤 no changes to commit")
+	} else if err := v.git.Commit(v.stdout, v.stderr, msg); err != nil {
 		return err
 	}
 
+	head, err := v.git.Head(v.stderr)
+	if err != nil {
+		return err
+	}
+
+	// the local vault is only synced up to the new commit if it was up to the previous one, as after an undo
+	// or a failed pull, the commits in between still need to be pulled
+	if isCommit(head) && (!isCommit(v.manifest.Commit) || v.manifest.Commit == previous) {
+		v.manifest.Commit = head
+		if err := v.manifest.Save(v.getManifestPath()); err != nil {
+			return err
+		}
+	}
+
 	if err := v.git.Push(v.stdout, v.stderr); err != nil {
 		return err
 	}
@@ -164,42 +463,199 @@ func (v *Vault) scan(t vaultType, check bool) error {
 		return err
 	}
 
-	if _, err := os.Stat(filepath.Join(path, v.config)); os.IsNotExist(err) && check {
-		return fmt.Errorf("not an obsidian vault: %s", path)
+	var directories, files []string
+	if t == vaultTypeGit && v.upstream != "" {
+		directories, files, err = v.listTree(v.upstream)
+		if err != nil {
+			return err
+		}
+
+		if check && !slices.Contains(directories, v.config) && !v.excluded(v.config, true) {
+			return fmt.Errorf("not an obsidian vault: %s at %s", path, shortHash(v.upstream))
+		}
+	} else {
+		if _, err := os.Stat(filepath.Join(path, v.config)); os.IsNotExist(err) && check && !v.excluded(v.config, true) {
+			return fmt.Errorf("not an obsidian vault: %s", path)
+		}
+
+		if directories, files, err = v.list(path); err != nil {
+			return err
+		}
 	}
 
-	v.directories = []string{}
-	v.files = []string{}
-	fn := func(p string, d fs.DirEntry, _ error) error {
-		if p == path {
-			return nil
+	v.directories = directories
+	v.files = files
+
+	zap.S().Debugf("scanned %d directories: %v", len(v.directories), v.directories)
+	zap.S().Debugf("scanned %d files: %v", len(v.files), v.files)
+
+	return nil
+}
+
+func (v *Vault) list(path string) ([]string, []string, error) {
+	directories := []string{}
+	files := []string{}
+
+	// links holds the real paths of the followed symlinks leading to the current root,
+	// so that a symlink to any of their ancestors is detected as a cycle.
+	var walk func(root, prefix string, links []string) error
+	walk = func(root, prefix string, links []string) error {
+		fn := func(p string, d fs.DirEntry, _ error) error {
+			if p == root {
+				return nil
+			}
+
+			if strings.HasPrefix(d.Name(), ".git") {
+				return filepath.SkipDir
+			}
+
+			if !d.IsDir() && (d.Name() == directoryMarker || d.Name() == remoteManifest) {
+				return nil
+			}
+
+			relativePath, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			relativePath = filepath.Join(prefix, relativePath)
+
+			isDir := d.IsDir()
+			if isSymlink(d.Type()) {
+				switch v.symlinks {
+				case SymlinkSkip:
+					zap.S().Debugf("skipped symlink: %s", relativePath)
+					return nil
+				case SymlinkFollow:
+					info, err := os.Stat(p)
+					if err != nil {
+						zap.S().Debugf("skipped broken symlink: %s", relativePath)
+						return nil
+					}
+					isDir = info.IsDir()
+				}
+			}
+
+			if v.excluded(relativePath, isDir) {
+				zap.S().Debugf("excluded path: %s", relativePath)
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if !isDir {
+				files = append(files, relativePath)
+				return nil
+			}
+
+			if d.IsDir() {
+				directories = append(directories, relativePath)
+				return nil
+			}
+
+			target, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return err
+			}
+
+			links := append(slices.Clone(links), p)
+			for _, link := range links {
+				if strings.HasPrefix(link, strings.TrimSuffix(target, string(filepath.Separator))+string(filepath.Separator)) {
+					zap.S().Warnf("skipped symlink cycle: %s", relativePath)
+					return nil
+				}
+			}
+
+			directories = append(directories, relativePath)
+			return walk(target, relativePath, links)
 		}
 
-		if strings.HasPrefix(d.Name(), ".git") {
-			return filepath.SkipDir
+		return filepath.WalkDir(root, fn)
+	}
+
+	root, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan vault: %w", err)
+	}
+
+	if err := walk(root, "", nil); err != nil {
+		return nil, nil, fmt.Errorf("failed to scan vault: %w", err)
+	}
+
+	return directories, files, nil
+}
+
+// listTree lists the directories and files of a git vault commit, as list does for the git vault on disk.
+func (v *Vault) listTree(rev string) ([]string, []string, error) {
+	objects, err := v.git.Tree(v.stderr, rev, ".")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := map[string]bool{}
+	directories := []string{}
+	files := []string{}
+	for _, object := range objects {
+		file := filepath.FromSlash(object.Path)
+		if filepath.Base(file) == remoteManifest || v.excludedTree(file) {
+			continue
 		}
 
-		relativePath, err := filepath.Rel(path, p)
-		if err != nil {
-			return err
+		var parents []string
+		for dir := filepath.Dir(file); dir != "." && !seen[dir]; dir = filepath.Dir(dir) {
+			seen[dir] = true
+			parents = append(parents, dir)
 		}
+		slices.Reverse(parents)
+		directories = append(directories, parents...)
 
-		if d.IsDir() {
-			v.directories = append(v.directories, relativePath)
-		} else {
-			v.files = append(v.files, relativePath)
+		if filepath.Base(file) != directoryMarker {
+			files = append(files, file)
 		}
+	}
 
-		return nil
+	return directories, files, nil
+}
+
+// excludedTree reports whether a file of a git vault commit, or any of its parent directories, is skipped by list.
+func (v *Vault) excludedTree(file string) bool {
+	for _, part := range strings.Split(file, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".git") {
+			return true
+		}
 	}
-	if err := filepath.WalkDir(path, fn); err != nil {
-		return fmt.Errorf("failed to scan vault: %w", err)
+
+	for dir := filepath.Dir(file); dir != "."; dir = filepath.Dir(dir) {
+		if v.excluded(dir, true) {
+			return true
+		}
 	}
 
-	zap.S().Debugf("scanned %d directories: %v", len(v.directories), v.directories)
-	zap.S().Debugf("scanned %d files: %v", len(v.files), v.files)
+	return filepath.Base(file) != directoryMarker && v.excluded(file, false)
+}
 
-	return nil
+// readGitFile reads an encrypted file of the git vault, or of the fetched upstream commit during a dry run pull.
+func (v *Vault) readGitFile(fileName string) ([]byte, error) {
+	if v.upstream == "" {
+		return os.ReadFile(filepath.Join(v.gitPath, fileName))
+	}
+
+	return v.readGitFileAt(v.upstream, fileName)
+}
+
+// readGitFileAt reads an encrypted file of the git vault at a revision.
+func (v *Vault) readGitFileAt(rev, fileName string) ([]byte, error) {
+	objects, err := v.git.Tree(v.stderr, rev, filepath.ToSlash(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("file %s not found at %s: %w", fileName, shortHash(rev), fs.ErrNotExist)
+	}
+
+	return v.git.Blob(v.stderr, objects[0].Hash)
 }
 
 func (v *Vault) clean(t vaultType, recreate bool) error {
@@ -208,6 +664,8 @@ func (v *Vault) clean(t vaultType, recreate bool) error {
 		return err
 	}
 
+	var directories []string
+	kept := map[string]bool{}
 	fn := func(p string, d fs.DirEntry, _ error) error {
 		if p == path {
 			return nil
@@ -217,41 +675,348 @@ func (v *Vault) clean(t vaultType, recreate bool) error {
 			return filepath.SkipDir
 		}
 
-		if d.IsDir() {
-			if err := os.RemoveAll(p); err != nil {
-				return fmt.Errorf("failed to remove directory %s: %w", p, err)
+		relativePath, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+
+		if v.excluded(relativePath, d.IsDir()) || (isSymlink(d.Type()) && v.symlinks != SymlinkStore) {
+			for dir := filepath.Dir(relativePath); dir != "."; dir = filepath.Dir(dir) {
+				kept[dir] = true
 			}
 
-			zap.S().Debugf("removed directory: %s", p)
-			return filepath.SkipDir
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
 		}
 
-		if err := os.Remove(p); err != nil {
-			return fmt.Errorf("failed to remove file %s: %w", p, err)
+		if d.IsDir() {
+			directories = append(directories, relativePath)
+			return nil
 		}
 
-		zap.S().Debugf("removed file: %s", p)
-		return nil
+		if t == vaultTypeLocal {
+			return v.discard(p)
+		}
+
+		return v.removeFile(p)
 	}
 
 	if err := filepath.WalkDir(path, fn); err != nil {
 		return fmt.Errorf("failed to clean vault: %w", err)
 	}
 
+	for i := len(directories) - 1; i >= 0; i-- {
+		if kept[directories[i]] {
+			continue
+		}
+
+		if err := v.removeDirectory(filepath.Join(path, directories[i])); err != nil {
+			return err
+		}
+	}
+
 	if !recreate {
 		return nil
 	}
 
 	for _, dir := range v.directories {
-		dirPath := filepath.Join(path, dir)
+		if err := v.createDirectory(filepath.Join(path, dir)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *Vault) updateGitVault() ([]string, error) {
+	gitDirectories, gitFiles, err := v.list(v.gitPath)
+	if err != nil {
+		return nil, err
+	}
+
+	localDirectories := toSet(v.directories)
+	localFiles := toSet(v.files)
+
+	kept := map[string]bool{}
+	for _, file := range gitFiles {
+		if localFiles[file] {
+			continue
+		}
+
+		if _, ok := v.manifest.Get(file); !ok {
+			zap.S().Warnf("skipped removal of file not pulled yet: %s", file)
+			for dir := filepath.Dir(file); dir != "."; dir = filepath.Dir(dir) {
+				kept[dir] = true
+			}
+			continue
+		}
+
+		if err := v.removeFile(filepath.Join(v.gitPath, file)); err != nil {
+			return nil, err
+		}
+
+		if err := v.bury(file); err != nil {
+			return nil, err
+		}
+	}
+
+	removed := map[string]bool{}
+	for _, dir := range gitDirectories {
+		if removed[filepath.Dir(dir)] {
+			removed[dir] = true
+			continue
+		}
+
+		if localDirectories[dir] || kept[dir] {
+			continue
+		}
+
+		if err := v.removeDirectory(filepath.Join(v.gitPath, dir)); err != nil {
+			return nil, err
+		}
+		removed[dir] = true
+	}
+
+	existing := toSet(gitDirectories)
+	for _, dir := range v.directories {
+		if existing[dir] {
+			continue
+		}
 
-		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dirPath, err)
+		if err := v.createDirectory(filepath.Join(v.gitPath, dir)); err != nil {
+			return nil, err
 		}
+	}
+
+	for name := range v.manifest.Files {
+		if !localFiles[name] {
+			v.manifest.Delete(name)
+		}
+	}
+
+	gitExisting := toSet(gitFiles)
+	var files []string
+	for _, file := range v.files {
+		localFile := filepath.Join(v.localPath, file)
+		info, err := v.stat(localFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file %s: %w", localFile, err)
+		}
+
+		if entry, ok := v.manifest.Get(file); ok && entry.Unchanged(info) {
+			// an unchanged file without a recorded hash is passed on, to have its ciphertext verified and recorded
+			if _, recorded := v.remote.File(file); gitExisting[file] && recorded {
+				continue
+			}
+
+			// the file was deleted on another device since the last pull, which the next pull applies
+			if _, buried := v.remote.Tombstone(file); buried && !gitExisting[file] {
+				zap.S().Warnf("skipped file deleted remotely and not pulled yet: %s", file)
+				continue
+			}
+		}
+
+		files = append(files, file)
+	}
+
+	zap.S().Debugf("updated %d files: %v", len(files), files)
+	return files, nil
+}
+
+func (v *Vault) markDirectories() error {
+	parents := map[string]bool{}
+	for _, entry := range v.directories {
+		parents[filepath.Dir(entry)] = true
+	}
+	for _, entry := range v.files {
+		parents[filepath.Dir(entry)] = true
+	}
+
+	for _, dir := range v.directories {
+		marker := filepath.Join(v.gitPath, dir, directoryMarker)
+		_, err := os.Stat(marker)
+		exists := err == nil
+
+		if parents[dir] {
+			if exists {
+				if err := v.removeFile(marker); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if exists {
+			continue
+		}
+
+		if v.dryRun {
+			zap.S().Infof("would create directory marker: %s", marker)
+			continue
+		}
+
+		if err := os.WriteFile(marker, nil, 0644); err != nil {
+			return fmt.Errorf("failed to write directory marker %s: %w", marker, err)
+		}
+
+		zap.S().Debugf("created directory marker: %s", marker)
+	}
+
+	return nil
+}
+
+func (v *Vault) updateLocalVault(head string, untrusted map[string]bool) ([]string, error) {
+	changes, err := v.git.Diff(v.stderr, v.manifest.Commit, head)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range v.directories {
+		dirPath := filepath.Join(v.localPath, dir)
+		if _, err := os.Stat(dirPath); err == nil {
+			continue
+		}
+
+		if err := v.createDirectory(dirPath); err != nil {
+			return nil, err
+		}
+	}
+
+	gitDirectories := toSet(v.directories)
+	gitFiles := toSet(v.files)
+
+	var files []string
+	for _, change := range changes {
+		file := filepath.FromSlash(change.Path)
+		localFile := filepath.Join(v.localPath, file)
+
+		if change.Status != git.StatusDeleted {
+			if !gitFiles[file] {
+				continue
+			}
+
+			if _, ok := v.manifest.Get(file); ok && change.Status == git.StatusModified {
+				if _, err := os.Lstat(localFile); err != nil {
+					zap.S().Warnf("restoring file deleted locally but edited remotely: %s", file)
+				}
+			}
+
+			files = append(files, file)
+			continue
+		}
+
+		if v.excluded(file, false) || untrusted[file] {
+			continue
+		}
+
+		if filepath.Base(file) != directoryMarker {
+			info, err := v.stat(localFile)
+			if err != nil {
+				v.manifest.Delete(file)
+				continue
+			}
+
+			edited, err := v.edited(file, info)
+			if err != nil {
+				return nil, err
+			}
+
+			tombstone, ok := v.remote.Tombstone(file)
+			if edited {
+				zap.S().Warnf("keeping file edited locally but deleted %s: %s", describeDeletion(tombstone, ok), file)
+				continue
+			}
+
+			zap.S().Debugf("removing file deleted %s: %s", describeDeletion(tombstone, ok), file)
+			if err := v.discard(localFile); err != nil {
+				return nil, err
+			}
+			v.manifest.Delete(file)
+		}
+
+		for dir := filepath.Dir(file); dir != "." && !gitDirectories[dir]; dir = filepath.Dir(dir) {
+			dirPath := filepath.Join(v.localPath, dir)
+			if entries, err := os.ReadDir(dirPath); err != nil || len(entries) > 0 {
+				break
+			}
+
+			if err := v.removeDirectory(dirPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// files left out of the last sync are forgotten, so that they are decrypted once selected again, along with
+	// the selected files never synced, either because the selection changed or because they are missing locally
+	for name := range v.manifest.Files {
+		if !gitFiles[name] && v.excludedTree(name) {
+			v.manifest.Delete(name)
+		}
+	}
+
+	updated := toSet(files)
+	reselected := v.manifest.Selection != v.selection()
+	for _, file := range v.files {
+		if _, ok := v.manifest.Get(file); ok || updated[file] || untrusted[file] {
+			continue
+		}
+
+		if !reselected {
+			if _, err := v.stat(filepath.Join(v.localPath, file)); err == nil {
+				continue
+			}
+		}
+
+		zap.S().Debugf("decrypting file not synced yet: %s", file)
+		files = append(files, file)
+	}
+
+	zap.S().Debugf("updated %d files: %v", len(files), files)
+	return files, nil
+}
+
+func (v *Vault) createDirectory(path string) error {
+	if v.dryRun {
+		zap.S().Infof("would create directory: %s", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+
+	zap.S().Debugf("created directory: %s", path)
+	return nil
+}
+
+func (v *Vault) removeDirectory(path string) error {
+	if v.dryRun {
+		zap.S().Infof("would remove directory: %s", path)
+		return nil
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove directory %s: %w", path, err)
+	}
+
+	zap.S().Debugf("removed directory: %s", path)
+	return nil
+}
+
+func (v *Vault) removeFile(path string) error {
+	if v.dryRun {
+		zap.S().Infof("would remove file: %s", path)
+		return nil
+	}
 
-		zap.S().Debugf("created directory: %s", dirPath)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove file %s: %w", path, err)
 	}
 
+	zap.S().Debugf("removed file: %s", path)
 	return nil
 }
 
@@ -266,34 +1031,90 @@ func (v *Vault) getVaultPath(t vaultType) (string, error) {
 	}
 }
 
-func (v *Vault) encrypt(password string) error {
-	channel := make(chan error, len(v.files))
+func (v *Vault) encrypt(files []string, password string) error {
+	return v.parallel(len(files), func(i int) error {
+		return v.encryptFile(files[i], password)
+	})
+}
 
-	for _, fileName := range v.files {
-		go func(fileName string) {
-			channel <- v.encryptFile(fileName, password)
-		}(fileName)
+// parallel runs fn for every index on the workers of the vault, returning the first error once every run is done,
+// as they change the manifests and vaults the caller goes on with. Every file encrypted or decrypted derives
+// a scrypt key taking 32MiB of memory, so the workers bound how many run at once.
+func (v *Vault) parallel(n int, fn func(i int) error) error {
+	channel := make(chan error, n)
+	for i := range n {
+		go func(i int) {
+			v.workers <- struct{}{}
+			defer func() { <-v.workers }()
+			channel <- fn(i)
+		}(i)
 	}
 
-	for range v.files {
-		if err := <-channel; err != nil {
-			return err
+	var first error
+	for range n {
+		if err := <-channel; err != nil && first == nil {
+			first = err
 		}
 	}
 
-	return nil
+	return first
 }
 
 func (v *Vault) encryptFile(fileName, password string) error {
 	localFile := filepath.Join(v.localPath, fileName)
 	gitFile := filepath.Join(v.gitPath, fileName)
 
-	data, err := os.ReadFile(localFile)
+	info, err := v.stat(localFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", localFile, err)
+	}
+
+	data, err := v.readFile(localFile, info)
 	if err != nil {
 		return fmt.Errorf("failed to read file %s: %w", localFile, err)
 	}
 
-	encrypted, err := v.crypto.Encrypt(data, password, fileName)
+	// the modification time is encrypted along with the content, so a touched file is encrypted again to keep it current
+	file := manifest.File{Size: info.Size(), ModTime: info.ModTime(), Mode: manifest.Executable(info.Mode()), Hash: manifest.Hash(data)}
+	unchanged := false
+	if entry, ok := v.manifest.Get(fileName); ok && entry.Hash == file.Hash && manifest.Executable(entry.Mode) == file.Mode && entry.ModTime.Equal(file.ModTime) {
+		_, err := os.Stat(gitFile)
+		unchanged = err == nil
+	}
+
+	if unchanged {
+		if _, ok := v.remote.File(fileName); ok || v.dryRun {
+			v.manifest.Set(fileName, file)
+			zap.S().Debugf("unchanged file: %s", localFile)
+			return nil
+		}
+	}
+
+	if v.dryRun {
+		zap.S().Infof("would encrypt file: %s", gitFile)
+		return nil
+	}
+
+	key, err := v.crypto.Key(password, fileName)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt file %s: %w", localFile, err)
+	}
+
+	// an unchanged file encrypted before the remote manifest recorded its hash is verified once before it is recorded
+	if unchanged {
+		hash, err := v.verify(gitFile, key, file.Hash)
+		if err == nil {
+			v.manifest.Set(fileName, file)
+			v.remote.Record(fileName, hash)
+			zap.S().Debugf("recorded unchanged file: %s", localFile)
+			return nil
+		}
+
+		zap.S().Warnf("encrypting again file not matching local vault: %s: %s", gitFile, err)
+	}
+
+	metadata := crypto.Metadata{ModTime: info.ModTime(), Mode: file.Mode, Symlink: isSymlink(info.Mode())}
+	encrypted, err := v.crypto.Seal(data, metadata, key)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt file %s: %w", localFile, err)
 	}
@@ -302,50 +1123,227 @@ func (v *Vault) encryptFile(fileName, password string) error {
 		return fmt.Errorf("failed to write file %s: %w", gitFile, err)
 	}
 
+	hash, err := v.verify(gitFile, key, file.Hash)
+	if err != nil {
+		return err
+	}
+
+	v.manifest.Set(fileName, file)
+	v.remote.Record(fileName, hash)
+	v.remote.Revive(fileName)
 	zap.S().Debugf("encrypted file: %s (%dB)", gitFile, len(encrypted))
 	return nil
 }
 
-func (v *Vault) decrypt(password string) error {
-	channel := make(chan error, len(v.files))
+// verify decrypts a written ciphertext back with the key it was encrypted with and compares it with the hash
+// of its plaintext, catching disk errors and encryption bugs before the ciphertext is committed.
+// It returns the hash of the ciphertext, to be recorded in the remote manifest.
+func (v *Vault) verify(path string, key []byte, hash string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
 
-	for _, fileName := range v.files {
-		go func(fileName string) {
-			channel <- v.decryptFile(fileName, password)
-		}(fileName)
+	decrypted, _, err := v.crypto.Open(data, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify file %s: %w", path, err)
 	}
 
-	for range v.files {
-		if err := <-channel; err != nil {
-			return err
-		}
+	if manifest.Hash(decrypted) != hash {
+		return "", fmt.Errorf("failed to verify file %s: decrypted content differs", path)
 	}
 
-	return nil
+	return manifest.Hash(data), nil
+}
+
+func (v *Vault) decrypt(files []string, password string) error {
+	return v.parallel(len(files), func(i int) error {
+		return v.decryptFile(files[i], password)
+	})
 }
 
 func (v *Vault) decryptFile(fileName, password string) error {
 	gitFile := filepath.Join(v.gitPath, fileName)
 	localFile := filepath.Join(v.localPath, fileName)
 
-	data, err := os.ReadFile(gitFile)
+	data, err := v.readGitFile(fileName)
 	if err != nil {
 		return fmt.Errorf("failed to read file %s: %w", gitFile, err)
 	}
 
-	decrypted, err := v.crypto.Decrypt(data, password, fileName)
+	decrypted, metadata, err := v.crypto.Decrypt(data, password, fileName)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt file %s: %w", gitFile, err)
 	}
 
-	if err := os.WriteFile(localFile, decrypted, 0644); err != nil {
-		return fmt.Errorf("failed to write file %s: %w", localFile, err)
+	if metadata.Symlink && v.symlinks == SymlinkSkip {
+		zap.S().Debugf("skipped symlink: %s", localFile)
+		return nil
+	}
+
+	if info, err := v.stat(localFile); err == nil && !info.IsDir() {
+		existing, err := v.readFile(localFile, info)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", localFile, err)
+		}
+
+		if !bytes.Equal(existing, decrypted) {
+			if err := v.discard(localFile); err != nil {
+				return err
+			}
+		}
 	}
 
+	if v.dryRun {
+		zap.S().Infof("would decrypt file: %s", localFile)
+		return nil
+	}
+
+	if err := writeFile(localFile, decrypted, metadata); err != nil {
+		return err
+	}
+
+	info, err := v.stat(localFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", localFile, err)
+	}
+
+	v.manifest.Set(fileName, manifest.File{Size: info.Size(), ModTime: info.ModTime(), Mode: manifest.Executable(info.Mode()), Hash: manifest.Hash(decrypted)})
 	zap.S().Debugf("decrypted file: %s (%dB)", localFile, len(decrypted))
 	return nil
 }
 
+// writeFile writes decrypted data and restores the executable bits and modification time from its metadata,
+// if any were encrypted along with it, or recreates the symlink it stores. The existing file is removed first,
+// as it may be read-only or a symlink that would otherwise be written through.
+func writeFile(path string, data []byte, metadata crypto.Metadata) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove file %s: %w", path, err)
+	}
+
+	if metadata.Symlink {
+		if err := os.Symlink(string(data), path); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", path, err)
+		}
+
+		return nil
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+
+	return setMetadata(path, metadata)
+}
+
+// setMetadata sets the executable bits and modification time of a file from its metadata, if any were encrypted
+// along with it. The executable bits are only granted where the umask let the file be readable.
+func setMetadata(path string, metadata crypto.Metadata) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	mode := info.Mode().Perm()
+	executable := (mode & 0444) >> 2 & manifest.Executable(metadata.Mode)
+	if mode&0111 != executable {
+		if err := os.Chmod(path, mode&^0111|executable); err != nil {
+			return fmt.Errorf("failed to change mode of file %s: %w", path, err)
+		}
+	}
+
+	if !metadata.ModTime.IsZero() {
+		if err := os.Chtimes(path, time.Time{}, metadata.ModTime); err != nil {
+			return fmt.Errorf("failed to change times of file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (v *Vault) excluded(relativePath string, isDir bool) bool {
+	for _, folder := range []string{trashFolder, backupFolder} {
+		if relativePath == folder || strings.HasPrefix(relativePath, folder+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	configPath, ok := v.getConfigPath(relativePath)
+	if ok && v.profile.Excludes(configPath) {
+		return true
+	}
+
+	if !ok && !v.selected(relativePath, isDir) {
+		return true
+	}
+
+	return v.ignore.Match(relativePath, isDir) || v.obsidian.Match(relativePath)
+}
+
+// selected reports whether a path is part of the folders selected for sync, either inside one of them
+// or, for directories, on the way to one of them. Every path is selected when no folder is.
+func (v *Vault) selected(relativePath string, isDir bool) bool {
+	if len(v.only) == 0 {
+		return true
+	}
+
+	for _, folder := range v.only {
+		if relativePath == folder || strings.HasPrefix(relativePath, folder+string(filepath.Separator)) {
+			return true
+		}
+
+		if isDir && strings.HasPrefix(folder, relativePath+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selection identifies the folders, config profile and exclude rules deciding which files are synced,
+// so that a pull after any of them changed can tell which files were left out until then.
+func (v *Vault) selection() string {
+	profile := v.profile
+	if profile == "" {
+		profile = obsidian.ProfileSettings
+	}
+
+	var only []string
+	for _, folder := range v.only {
+		only = append(only, filepath.ToSlash(folder))
+	}
+	slices.Sort(only)
+
+	parts := []string{v.config, string(profile), strings.Join(only, "\n"), string(v.symlinks), v.ignore.String(), v.obsidian.String()}
+	return manifest.Hash([]byte(strings.Join(parts, "\x00")))
+}
+
+func (v *Vault) getConfigPath(relativePath string) (string, bool) {
+	configPath, err := filepath.Rel(v.config, relativePath)
+	if err != nil || configPath == ".." || strings.HasPrefix(configPath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return configPath, true
+}
+
+func (v *Vault) getManifestPath() string {
+	return filepath.Join(v.gitPath, git.HiddenFolder, "obsidian-vault.json")
+}
+
+func isCommit(value string) bool {
+	return commitRegexp.MatchString(value)
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+
+	return set
+}
+
 func getShell() string {
 	shell, ok := os.LookupEnv("SHELL")
 	if !ok {
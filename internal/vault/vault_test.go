@@ -1,14 +1,113 @@
 package vault
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/jhandguy/obsidian-vault/internal/crypto"
+	"github.com/jhandguy/obsidian-vault/internal/manifest"
+	"github.com/jhandguy/obsidian-vault/internal/obsidian"
+	"github.com/jhandguy/obsidian-vault/internal/retention"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
+const testPassword = "consectetur-adipiscing-elit"
+
+// copyExample copies the example vault into a temporary folder with the given name.
+func copyExample(t *testing.T, name string) string {
+	t.Helper()
+
+	pwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	if filepath.Base(path) == name {
+		return path
+	}
+
+	dst := filepath.Join(filepath.Dir(path), name)
+	err = os.Rename(path, dst)
+	require.NoError(t, err)
+
+	return dst
+}
+
+// newTestVault creates a vault with an empty git vault, where git commands are echoed instead of run.
+func newTestVault(t *testing.T, path string, options Options) *Vault {
+	t.Helper()
+	t.Setenv("SHELL", "echo")
+
+	v, err := New(path, ".obsidian", options)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	require.NoError(t, err)
+
+	return v
+}
+
+// newTestRemote creates a bare git repository with an initial commit on main, standing in for the GitHub repository.
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "ov")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ov@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ov")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ov@example.com")
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, "", "init", "-q", "--bare", "-b", "main", remote)
+
+	seed := filepath.Join(t.TempDir(), "seed")
+	runGit(t, "", "clone", "-q", remote, seed)
+	t.Setenv("GIT_COMMITTER_DATE", "2026-01-01T00:00:00Z")
+	runGit(t, seed, "commit", "-q", "--allow-empty", "--date", "2026-01-01T00:00:00Z", "-m", "initial commit")
+	os.Unsetenv("GIT_COMMITTER_DATE")
+	runGit(t, seed, "push", "-q", "origin", "HEAD:main")
+
+	return remote
+}
+
+// cloneTestVault creates a vault whose git vault is a clone of the remote, where git commands run for real.
+func cloneTestVault(t *testing.T, remote, path string, options Options) *Vault {
+	t.Helper()
+	t.Setenv("SHELL", "sh")
+
+	err := os.MkdirAll(filepath.Join(path, ".obsidian"), os.ModePerm)
+	require.NoError(t, err)
+
+	v, err := New(path, ".obsidian", options)
+	require.NoError(t, err)
+
+	runGit(t, "", "clone", "-q", remote, v.gitPath)
+	return v
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	return strings.TrimSpace(string(output))
+}
+
 func TestExample(t *testing.T) {
 	pwd, err := os.Getwd()
 	assert.NoError(t, err)
@@ -20,7 +119,7 @@ func TestExample(t *testing.T) {
 	err = os.Setenv("SHELL", "echo")
 	assert.NoError(t, err)
 
-	v, err := New(path, config)
+	v, err := New(path, config, Options{ConfigProfile: obsidian.ProfileFull})
 	assert.NoError(t, err)
 
 	gitPath, err := v.getVaultPath(vaultTypeGit)
@@ -30,7 +129,7 @@ func TestExample(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.RemoveAll(gitPath)
 
-	err = v.Push(password)
+	err = v.Push(password, false, DeleteLimit{})
 	assert.NoError(t, err)
 
 	tmpPath := filepath.Join(pwd, "tmp")
@@ -46,7 +145,7 @@ func TestExample(t *testing.T) {
 		assert.NoError(t, err)
 	}
 
-	err = v.Pull(password)
+	err = v.Pull(password, false)
 	assert.NoError(t, err)
 
 	for _, file := range v.files {
@@ -59,7 +158,7 @@ func TestExample(t *testing.T) {
 	}
 
 	v.localPath = tmpPath
-	err = v.Clean(true)
+	err = v.Clean(true, false)
 	assert.NoError(t, err)
 
 	for _, file := range v.files {
@@ -70,3 +169,1908 @@ func TestExample(t *testing.T) {
 		assert.True(t, os.IsNotExist(err))
 	}
 }
+
+func TestDryRun(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := filepath.Join(pwd, "../../example")
+	config := ".obsidian"
+	password := "consectetur-adipiscing-elit"
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, config, Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+	defer os.RemoveAll(v.gitPath)
+
+	err = v.Push(password, true, DeleteLimit{})
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(v.gitPath)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	err = v.Clean(true, true)
+	assert.NoError(t, err)
+
+	for _, file := range v.files {
+		_, err := os.Stat(filepath.Join(v.localPath, file))
+		assert.NoError(t, err)
+	}
+
+	_, err = os.Stat(v.gitPath)
+	assert.NoError(t, err)
+}
+
+func TestIncrementalPush(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	config := ".obsidian"
+	password := "consectetur-adipiscing-elit"
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, config, Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	unchangedFile := filepath.Join("folder-1", "File-1.md")
+	changedFile := filepath.Join("folder-1", "File-2.md")
+	removedFile := filepath.Join("folder-2", "folder-3", "File-3.md")
+
+	unchanged, err := os.ReadFile(filepath.Join(v.gitPath, unchangedFile))
+	assert.NoError(t, err)
+
+	changed, err := os.ReadFile(filepath.Join(v.gitPath, changedFile))
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(path, changedFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = os.RemoveAll(filepath.Join(path, filepath.Dir(removedFile)))
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	encrypted, err := os.ReadFile(filepath.Join(v.gitPath, unchangedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, unchanged, encrypted)
+
+	encrypted, err = os.ReadFile(filepath.Join(v.gitPath, changedFile))
+	assert.NoError(t, err)
+	assert.NotEqual(t, changed, encrypted)
+
+	decrypted, _, err := v.crypto.Decrypt(encrypted, password, changedFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "Lorem ipsum", string(decrypted))
+
+	_, err = os.Stat(filepath.Join(v.gitPath, filepath.Dir(removedFile)))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func copyVault(t *testing.T, src string) string {
+	dst := filepath.Join(t.TempDir(), filepath.Base(src))
+
+	fn := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".git") {
+			return filepath.SkipDir
+		}
+
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, relativePath), os.ModePerm)
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(dst, relativePath), data, 0644)
+	}
+	err := filepath.WalkDir(src, fn)
+	assert.NoError(t, err)
+
+	return dst
+}
+
+func TestIncrementalPull(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	editedFile := filepath.Join("folder-1", "File-1.md")
+	deletedFile := filepath.Join("folder-1", "File-2.md")
+	addedFile := filepath.Join("folder-1", "File-4.md")
+	keptFile := filepath.Join("folder-2", "folder-3", "File-3.md")
+	redrawnFile := "Draw.canvas"
+
+	data, err := os.ReadFile(filepath.Join(b.localPath, editedFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "# Title 1")
+
+	err = os.WriteFile(filepath.Join(a.localPath, editedFile), []byte("Edited"), 0644)
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(a.localPath, deletedFile))
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(a.localPath, addedFile), []byte("Added"), 0644)
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(a.localPath, keptFile))
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(a.localPath, redrawnFile), []byte("{}"), 0644)
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	// the file deleted on a is edited on b, and the file edited on a is deleted on b
+	err = os.WriteFile(filepath.Join(b.localPath, keptFile), []byte("Kept"), 0644)
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(b.localPath, redrawnFile))
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	for file, content := range map[string]string{editedFile: "Edited", addedFile: "Added", keptFile: "Kept", redrawnFile: "{}"} {
+		data, err := os.ReadFile(filepath.Join(b.localPath, file))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	_, err = os.Stat(filepath.Join(b.localPath, deletedFile))
+	assert.True(t, os.IsNotExist(err))
+
+	names, err := b.listTrashes()
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	trashed, err := os.ReadFile(filepath.Join(b.getTrashPath(), names[0], deletedFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(trashed), "# Title 2")
+
+	m, err := manifest.Load(b.getManifestPath())
+	assert.NoError(t, err)
+	assert.Equal(t, runGit(t, "", "--git-dir", remote, "rev-parse", "main"), m.Commit)
+
+	// the file kept on b is pushed back and restored on a
+	err = b.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = a.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(filepath.Join(a.localPath, keptFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "Kept", string(data))
+}
+
+func TestGetRelativePath(t *testing.T) {
+	v, err := New(t.TempDir(), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	path, err := v.getRelativePath(filepath.Join("folder-1", "File-1.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("folder-1", "File-1.md"), path)
+
+	path, err = v.getRelativePath(filepath.Join(v.localPath, "folder-1", "File-1.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("folder-1", "File-1.md"), path)
+
+	_, err = v.getRelativePath(filepath.Join("..", "File-1.md"))
+	assert.Error(t, err)
+}
+
+func TestLog(t *testing.T) {
+	remote := newTestRemote(t)
+	v := cloneTestVault(t, remote, copyExample(t, "example"), Options{})
+	note := filepath.Join("folder-1", "File-1.md")
+
+	err := v.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(v.localPath, note), []byte("# Title 1\n"), 0644)
+	require.NoError(t, err)
+
+	err = v.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	err = v.Log(&stdout, note, testPassword, true)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "obsidian-vault backup")
+	assert.Contains(t, lines[0], "+0 -4")
+	assert.Contains(t, lines[1], "+5 -0")
+
+	assert.Contains(t, lines[0], "10B")
+	assert.NotContains(t, lines[0], "encrypted")
+
+	stdout.Reset()
+	err = v.Log(&stdout, note, "", false)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), "B encrypted")
+
+	err = v.Log(&stdout, "missing.md", testPassword, false)
+	assert.Error(t, err)
+}
+
+func TestDiffLocalFiles(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(filepath.Join(pwd, "../../example"), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	var stdout bytes.Buffer
+	err = v.Diff(&stdout, "folder-1", "", "", "consectetur-adipiscing-elit")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout.String(), "--- /dev/null\n+++ b/folder-1/File-1.md\n@@ -0,0 +1,5 @@\n+# Title 1\n")
+	assert.Contains(t, stdout.String(), "--- /dev/null\n+++ b/folder-1/File-2.md\n")
+	assert.NotContains(t, stdout.String(), "File-3.md")
+}
+
+func TestRestoreWithoutHistory(t *testing.T) {
+	err := os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(t.TempDir(), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = v.Restore(filepath.Join("folder-1", "File-1.md"), "HEAD", "", "consectetur-adipiscing-elit")
+	assert.ErrorContains(t, err, "no files found")
+}
+
+func TestRestoreAllRequiresEmptyOutput(t *testing.T) {
+	err := os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(t.TempDir(), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	password := "consectetur-adipiscing-elit"
+
+	err = v.RestoreAll("2026-09-01", "", password)
+	assert.ErrorContains(t, err, "output directory is required")
+
+	err = os.WriteFile(filepath.Join(v.localPath, "File-1.md"), nil, 0644)
+	assert.NoError(t, err)
+
+	err = v.RestoreAll("2026-09-01", v.localPath, password)
+	assert.ErrorContains(t, err, "output directory is not empty")
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	remote := newTestRemote(t)
+	v := cloneTestVault(t, remote, copyExample(t, "example"), Options{})
+
+	err := v.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = v.CreateSnapshot("before cleanup", "HEAD")
+	assert.ErrorContains(t, err, "invalid snapshot name")
+
+	err = v.CreateSnapshot("before-cleanup", "HEAD")
+	require.NoError(t, err)
+
+	tag := snapshotPrefix + "before-cleanup"
+	assert.Equal(t, "tag", runGit(t, "", "--git-dir", remote, "cat-file", "-t", tag))
+	assert.Equal(t, runGit(t, "", "--git-dir", remote, "rev-parse", "main"), runGit(t, "", "--git-dir", remote, "rev-parse", tag+"^{commit}"))
+
+	msg := runGit(t, "", "--git-dir", remote, "tag", "-l", "--format=%(contents)", tag)
+	assert.True(t, strings.HasPrefix(msg, "obsidian-vault snapshot before-cleanup\n"))
+
+	objects, err := v.getObjects("HEAD", ".")
+	require.NoError(t, err)
+	assert.NotEmpty(t, objects)
+
+	device, err := os.Hostname()
+	require.NoError(t, err)
+
+	metadata := parseSnapshotMetadata(msg)
+	assert.Equal(t, fmt.Sprint(len(objects)), metadata["files"])
+	assert.Equal(t, device, metadata["device"])
+
+	_, err = time.Parse(time.RFC3339, metadata["timestamp"])
+	assert.NoError(t, err)
+}
+
+func TestParseSnapshotMetadata(t *testing.T) {
+	metadata := parseSnapshotMetadata("obsidian-vault snapshot name\n\nfiles: 42\ndevice: laptop\ntimestamp: 2026-10-17T10:00:00+02:00")
+	assert.Equal(t, map[string]string{"files": "42", "device": "laptop", "timestamp": "2026-10-17T10:00:00+02:00"}, metadata)
+}
+
+func TestPrune(t *testing.T) {
+	remote := newTestRemote(t)
+	v := cloneTestVault(t, remote, copyExample(t, "example"), Options{})
+	note := filepath.Join("folder-1", "File-1.md")
+
+	now := time.Now()
+	for i, age := range []int{60, 40, 0} {
+		date := now.AddDate(0, 0, -age).Format(time.RFC3339)
+		t.Setenv("GIT_AUTHOR_DATE", date)
+		t.Setenv("GIT_COMMITTER_DATE", date)
+
+		err := os.WriteFile(filepath.Join(v.localPath, note), []byte(fmt.Sprintf("Version %d", i)), 0644)
+		require.NoError(t, err)
+
+		err = v.Push(testPassword, false, DeleteLimit{})
+		require.NoError(t, err)
+	}
+	os.Unsetenv("GIT_AUTHOR_DATE")
+	os.Unsetenv("GIT_COMMITTER_DATE")
+
+	commits, err := v.git.History(v.stderr)
+	require.NoError(t, err)
+	assert.Len(t, commits, 4)
+
+	err = v.Prune(retention.New(7, 30, 0), true, nil)
+	assert.NoError(t, err)
+
+	commits, err = v.git.History(v.stderr)
+	require.NoError(t, err)
+	assert.Len(t, commits, 4)
+
+	var dropped int
+	err = v.Prune(retention.New(7, 30, 0), false, func(_, d int) bool {
+		dropped = d
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, dropped)
+
+	commits, err = v.git.History(v.stderr)
+	require.NoError(t, err)
+	assert.Len(t, commits, 1)
+
+	head, err := v.git.Head(v.stderr)
+	require.NoError(t, err)
+	assert.Equal(t, head, runGit(t, "", "--git-dir", remote, "rev-parse", "main"))
+
+	m, err := manifest.Load(v.getManifestPath())
+	assert.NoError(t, err)
+	assert.Equal(t, head, m.Commit)
+
+	output := t.TempDir()
+	err = v.Restore(note, "HEAD", output, testPassword)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(output, note))
+	assert.NoError(t, err)
+	assert.Equal(t, "Version 2", string(data))
+}
+
+func TestIsWatchNoise(t *testing.T) {
+	v, err := New(t.TempDir(), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	assert.True(t, v.isWatchNoise(".git-vault"))
+	assert.True(t, v.isWatchNoise(filepath.Join(".obsidian", "workspace.json")))
+	assert.False(t, v.isWatchNoise(filepath.Join(".obsidian", "app.json")))
+	assert.False(t, v.isWatchNoise(filepath.Join("folder-1", "workspace.json")))
+}
+
+func TestWatch(t *testing.T) {
+	remote := newTestRemote(t)
+	v := cloneTestVault(t, remote, copyExample(t, "example"), Options{})
+	countCommits := func() string {
+		return runGit(t, "", "--git-dir", remote, "rev-list", "--count", "main")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- v.Watch(ctx, testPassword, 50*time.Millisecond)
+	}()
+
+	assert.Eventually(t, func() bool { return countCommits() == "2" }, 10*time.Second, 50*time.Millisecond)
+
+	err := os.WriteFile(filepath.Join(v.localPath, "folder-1", "File-4.md"), []byte("Watched"), 0644)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return countCommits() == "3" }, 10*time.Second, 50*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestBackoff(t *testing.T) {
+	interval := 15 * time.Minute
+	assert.Equal(t, time.Minute, backoff(1, interval))
+	assert.Equal(t, 2*time.Minute, backoff(2, interval))
+	assert.Equal(t, 8*time.Minute, backoff(4, interval))
+	assert.Equal(t, interval, backoff(5, interval))
+	assert.Equal(t, interval, backoff(29, interval))
+	assert.Equal(t, interval, backoff(30, interval))
+	assert.Equal(t, interval, backoff(100, interval))
+}
+
+func TestDaemon(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+	statusPath := filepath.Join(t.TempDir(), "status.json")
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(a.localPath, "folder-1", "File-4.md"), []byte("Synced"), 0644)
+	require.NoError(t, err)
+
+	// a cancelled context runs a single sync
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = a.Daemon(ctx, testPassword, time.Hour, statusPath)
+	require.NoError(t, err)
+
+	var s status
+	data, err := os.ReadFile(statusPath)
+	require.NoError(t, err)
+	err = json.Unmarshal(data, &s)
+	require.NoError(t, err)
+	assert.Zero(t, s.Failures)
+	assert.False(t, s.LastSuccess.IsZero())
+	assert.Empty(t, s.LastError)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(filepath.Join(b.localPath, "folder-1", "File-4.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Synced", string(data))
+
+	err = os.WriteFile(filepath.Join(a.localPath, "folder-1", "File-5.md"), []byte("Unsynced"), 0644)
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Daemon(ctx, "wrong-password", time.Hour, statusPath)
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(statusPath)
+	require.NoError(t, err)
+	err = json.Unmarshal(data, &s)
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.Failures)
+	assert.NotEmpty(t, s.LastError)
+}
+
+func TestIgnore(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+
+	err = os.WriteFile(filepath.Join(path, ".ovignore"), []byte("folder-2/\n*.canvas\n"), 0644)
+	assert.NoError(t, err)
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, ".ovignore"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "Draw.canvas"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-2"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = v.Clean(false, false)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, "Draw.canvas"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, "folder-2", "folder-3", "File-3.md"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, "folder-1"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestObsidianExcludes(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+
+	err = os.WriteFile(filepath.Join(path, ".obsidian", "app.json"), []byte(`{"userIgnoreFilters": ["folder-2/", "/\\.canvas$/"]}`), 0644)
+	assert.NoError(t, err)
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{ObsidianExcludes: true})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "Draw.canvas"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-2"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-1"))
+	assert.NoError(t, err)
+}
+
+func TestConfigProfile(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{ConfigProfile: obsidian.ProfileSettings})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, ".obsidian", "app.json"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, ".obsidian", "workspace.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	v, err = New(path, ".obsidian", Options{ConfigProfile: obsidian.ProfileNone})
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "Draw.canvas"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, ".obsidian", "workspace.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, ".obsidian", "workspace.json"))
+	assert.NoError(t, err)
+}
+
+func TestOnly(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{Only: []string{"folder-2/folder-3/"}})
+	assert.NoError(t, err)
+
+	assert.True(t, v.selected("folder-2", true))
+	assert.False(t, v.selected(filepath.Join("folder-2", "File.md"), false))
+	assert.True(t, v.selected(filepath.Join("folder-2", "folder-3", "File-3.md"), false))
+	assert.False(t, v.selected("folder-1", true))
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-2", "folder-3", "File-3.md"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, ".obsidian", "app.json"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-1"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "Draw.canvas"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, "folder-1", "File-1.md"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, "Draw.canvas"))
+	assert.NoError(t, err)
+
+	_, err = New(path, ".obsidian", Options{Only: []string{"../outside"}})
+	assert.Error(t, err)
+}
+
+func TestWidenSelection(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{ConfigProfile: obsidian.ProfileFull})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{Only: []string{"folder-1"}})
+	selectedFile := filepath.Join("folder-1", "File-1.md")
+	widenedFile := filepath.Join("folder-2", "folder-3", "File-3.md")
+	workspaceFile := filepath.Join(".obsidian", "workspace.json")
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(b.localPath, selectedFile))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(b.localPath, widenedFile))
+	assert.True(t, os.IsNotExist(err))
+
+	b, err = New(b.localPath, ".obsidian", Options{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(b.localPath, widenedFile))
+	assert.NoError(t, err)
+	expected, err := os.ReadFile(filepath.Join(a.localPath, widenedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+
+	_, err = os.Stat(filepath.Join(b.localPath, workspaceFile))
+	assert.True(t, os.IsNotExist(err))
+
+	b, err = New(b.localPath, ".obsidian", Options{ConfigProfile: obsidian.ProfileFull})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(b.localPath, workspaceFile))
+	assert.NoError(t, err)
+
+	err = os.Remove(filepath.Join(b.localPath, widenedFile))
+	require.NoError(t, err)
+
+	b.manifest.Delete(widenedFile)
+	err = b.manifest.Save(b.getManifestPath())
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(b.localPath, widenedFile))
+	assert.NoError(t, err)
+}
+
+func TestPreserveMetadata(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	file := filepath.Join(path, "folder-1", "File-1.md")
+	modTime := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	err = os.Chmod(file, 0755)
+	assert.NoError(t, err)
+
+	err = os.Chtimes(file, modTime, modTime)
+	assert.NoError(t, err)
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	err = os.Remove(file)
+	assert.NoError(t, err)
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.True(t, modTime.Equal(info.ModTime()))
+	if runtime.GOOS != "windows" {
+		assert.NotZero(t, info.Mode().Perm()&0100)
+	}
+}
+
+func TestEmptyDirectories(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	emptyDir := filepath.Join("folder-1", "empty")
+
+	err = os.MkdirAll(filepath.Join(path, emptyDir), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, emptyDir, directoryMarker))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-1", directoryMarker))
+	assert.True(t, os.IsNotExist(err))
+
+	err = os.Remove(filepath.Join(path, emptyDir))
+	assert.NoError(t, err)
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, emptyDir))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(path, emptyDir, directoryMarker))
+	assert.True(t, os.IsNotExist(err))
+
+	err = os.WriteFile(filepath.Join(path, emptyDir, "File.md"), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, emptyDir, directoryMarker))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSymlinks(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	external := t.TempDir()
+
+	err = os.WriteFile(filepath.Join(external, "External.md"), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = os.Symlink(external, filepath.Join(path, "external"))
+	assert.NoError(t, err)
+
+	err = os.Symlink(filepath.Join("folder-1", "File-1.md"), filepath.Join(path, "Link.md"))
+	assert.NoError(t, err)
+
+	err = os.Symlink("..", filepath.Join(path, "folder-1", "loop"))
+	assert.NoError(t, err)
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{Symlinks: SymlinkSkip})
+	assert.NoError(t, err)
+
+	err = v.scan(vaultTypeLocal, true)
+	assert.NoError(t, err)
+	assert.NotContains(t, v.files, "Link.md")
+	assert.NotContains(t, v.files, "external")
+
+	v, err = New(path, ".obsidian", Options{Symlinks: SymlinkFollow})
+	assert.NoError(t, err)
+
+	err = v.scan(vaultTypeLocal, true)
+	assert.NoError(t, err)
+	assert.Contains(t, v.files, "Link.md")
+	assert.Contains(t, v.directories, "external")
+	assert.Contains(t, v.files, filepath.Join("external", "External.md"))
+	assert.NotContains(t, v.directories, filepath.Join("folder-1", "loop"))
+
+	v, err = New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	info, err := os.Lstat(filepath.Join(v.gitPath, "external"))
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+
+	err = os.Remove(filepath.Join(path, "Link.md"))
+	assert.NoError(t, err)
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	target, err := os.Readlink(filepath.Join(path, "Link.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("folder-1", "File-1.md"), target)
+
+	_, err = os.Stat(filepath.Join(external, "External.md"))
+	assert.NoError(t, err)
+}
+
+func TestTombstones(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	deletedFile := filepath.Join("folder-1", "File-1.md")
+	remoteFile := filepath.Join("folder-1", "Remote.md")
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	err = os.Remove(filepath.Join(path, deletedFile))
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(v.gitPath, remoteFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, deletedFile))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(v.gitPath, remoteFile))
+	assert.NoError(t, err)
+
+	err = v.loadRemote(password)
+	assert.NoError(t, err)
+
+	tombstone, ok := v.remote.Tombstone(deletedFile)
+	assert.True(t, ok)
+	assert.NotEmpty(t, tombstone.Device)
+
+	_, ok = v.remote.Tombstone(remoteFile)
+	assert.False(t, ok)
+
+	editedFile := filepath.Join("folder-1", "File-2.md")
+	info, err := os.Stat(filepath.Join(path, editedFile))
+	assert.NoError(t, err)
+
+	edited, err := v.edited(editedFile, info)
+	assert.NoError(t, err)
+	assert.False(t, edited)
+
+	err = os.WriteFile(filepath.Join(path, editedFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	info, err = os.Stat(filepath.Join(path, editedFile))
+	assert.NoError(t, err)
+
+	edited, err = v.edited(editedFile, info)
+	assert.NoError(t, err)
+	assert.True(t, edited)
+
+	err = os.WriteFile(filepath.Join(path, deletedFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, ok = v.remote.Tombstone(deletedFile)
+	assert.False(t, ok)
+}
+
+func TestTrash(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	editedFile := filepath.Join("folder-1", "File-1.md")
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(path, editedFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	names, err := v.listTrashes()
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	trashed, err := os.ReadFile(filepath.Join(v.getTrashPath(), names[0], editedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "Lorem ipsum", string(trashed))
+
+	var buf bytes.Buffer
+	err = v.ListTrash(&buf, names[0])
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), editedFile)
+
+	err = v.scan(vaultTypeLocal, true)
+	assert.NoError(t, err)
+	assert.NotContains(t, v.directories, trashFolder)
+
+	err = v.RestoreTrash(names[0], editedFile)
+	assert.NoError(t, err)
+
+	restored, err := os.ReadFile(filepath.Join(path, editedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "Lorem ipsum", string(restored))
+
+	err = v.RestoreTrash(names[0], "missing.md")
+	assert.Error(t, err)
+
+	err = v.EmptyTrash("")
+	assert.NoError(t, err)
+
+	_, err = os.Stat(v.getTrashPath())
+	assert.True(t, os.IsNotExist(err))
+
+	err = v.EmptyTrash("../folder-1")
+	assert.Error(t, err)
+}
+
+func TestDeleteLimit(t *testing.T) {
+	assert.False(t, DeleteLimit{}.exceeded(1000, 1000))
+	assert.True(t, DeleteLimit{Count: 10}.exceeded(11, 1000))
+	assert.False(t, DeleteLimit{Count: 10}.exceeded(10, 1000))
+	assert.True(t, DeleteLimit{Fraction: 0.5}.exceeded(6, 10))
+	assert.False(t, DeleteLimit{Fraction: 0.5}.exceeded(5, 10))
+	assert.False(t, DeleteLimit{Fraction: 0.5}.exceeded(0, 0))
+}
+
+func TestMassDeleteSafeguard(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DefaultDeleteLimit)
+	assert.NoError(t, err)
+
+	for _, file := range []string{"Draw.canvas", "folder-1", "folder-2", filepath.Join(".obsidian", "app.json")} {
+		err = os.RemoveAll(filepath.Join(path, file))
+		assert.NoError(t, err)
+	}
+
+	err = v.Push(password, false, DefaultDeleteLimit)
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-1", "File-1.md"))
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(v.gitPath, "folder-1", "File-1.md"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUndo(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	editedFile := filepath.Join("folder-1", "File-1.md")
+	newFile := filepath.Join("folder-1", "New.md")
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{Backups: 2, EncryptBackups: true})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"2026-01-01T00-00-00.tar.gz", "2026-01-02T00-00-00.tar.gz.enc"} {
+		err = os.MkdirAll(v.getBackupPath(), os.ModePerm)
+		assert.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(v.getBackupPath(), name), nil, 0600)
+		assert.NoError(t, err)
+	}
+
+	canvas := filepath.Join(path, "Draw.canvas")
+	info, err := os.Stat(canvas)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(path, editedFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = v.Pull(password, false)
+	assert.NoError(t, err)
+
+	names, err := v.listBackups()
+	assert.NoError(t, err)
+	assert.Len(t, names, 2)
+	assert.Equal(t, "2026-01-02T00-00-00.tar.gz.enc", names[0])
+
+	pulled, err := os.ReadFile(filepath.Join(path, editedFile))
+	assert.NoError(t, err)
+	assert.NotEqual(t, "Lorem ipsum", string(pulled))
+
+	// as if the pull only changed the mode and modification time of the file
+	err = os.Chmod(canvas, 0755)
+	assert.NoError(t, err)
+
+	err = os.Chtimes(canvas, time.Time{}, info.ModTime().Add(time.Hour))
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(path, newFile), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = v.Undo("")
+	assert.Error(t, err)
+
+	err = v.Undo(password)
+	assert.NoError(t, err)
+
+	restored, err := os.ReadFile(filepath.Join(path, editedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "Lorem ipsum", string(restored))
+
+	_, err = os.Stat(filepath.Join(path, newFile))
+	assert.True(t, os.IsNotExist(err))
+
+	undone, err := os.Stat(canvas)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(undone.ModTime()))
+	if runtime.GOOS != "windows" {
+		assert.Zero(t, undone.Mode().Perm()&0111)
+	}
+
+	names, err = v.listBackups()
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	names, err = v.listTrashes()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, names)
+
+	err = v.Clean(false, true)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(v.getBackupPath())
+	assert.NoError(t, err)
+
+	err = v.Clean(false, false)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(v.getBackupPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestVerify(t *testing.T) {
+	v, err := New(t.TempDir(), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	password := "consectetur-adipiscing-elit"
+	fileName := "LoremIpsum.md"
+	plaintext := []byte("Lorem ipsum")
+	path := filepath.Join(t.TempDir(), fileName)
+
+	key, err := v.crypto.Key(password, fileName)
+	assert.NoError(t, err)
+
+	encrypted, err := v.crypto.Seal(plaintext, crypto.Metadata{}, key)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(path, encrypted, 0644)
+	assert.NoError(t, err)
+
+	hash, err := v.verify(path, key, manifest.Hash(plaintext))
+	assert.NoError(t, err)
+	assert.Equal(t, manifest.Hash(encrypted), hash)
+
+	_, err = v.verify(path, key, manifest.Hash([]byte("Dolor sit amet")))
+	assert.Error(t, err)
+
+	encrypted[len(encrypted)-1] ^= 1
+	err = os.WriteFile(path, encrypted, 0644)
+	assert.NoError(t, err)
+
+	_, err = v.verify(path, key, manifest.Hash(plaintext))
+	assert.Error(t, err)
+}
+
+func TestVerifyRepository(t *testing.T) {
+	err := os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(t.TempDir(), ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	password := "consectetur-adipiscing-elit"
+
+	var stdout bytes.Buffer
+	err = v.Verify(&stdout, password, false)
+	assert.ErrorContains(t, err, "no files found")
+
+	err = v.Verify(&stdout, password, true)
+	assert.ErrorContains(t, err, "no files found")
+
+	fileName := "LoremIpsum.md"
+	encrypted, err := v.crypto.Encrypt([]byte("Lorem ipsum"), crypto.Metadata{}, password, fileName)
+	assert.NoError(t, err)
+
+	_, _, err = v.crypto.Decrypt(encrypted, "sed-do-eiusmod", fileName)
+	assert.Equal(t, "encrypted under a different password", describeFailure(err))
+
+	_, _, err = v.crypto.Decrypt(encrypted[:len(encrypted)-1], password, fileName)
+	assert.Equal(t, "truncated", describeFailure(err))
+
+	encrypted[len(encrypted)-1] ^= 1
+	_, _, err = v.crypto.Decrypt(encrypted, password, fileName)
+	assert.Equal(t, "failed authentication", describeFailure(err))
+}
+
+func TestRemoteManifest(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path := copyVault(t, filepath.Join(pwd, "../../example"))
+	password := "consectetur-adipiscing-elit"
+	rolledBackFile := filepath.Join("folder-1", "File-1.md")
+	missingFile := filepath.Join("folder-1", "File-2.md")
+	addedFile := filepath.Join("folder-1", "Added.md")
+	deletedFile := filepath.Join("folder-2", "folder-3", "File-3.md")
+
+	err = os.Setenv("SHELL", "echo")
+	assert.NoError(t, err)
+
+	v, err := New(path, ".obsidian", Options{})
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(v.gitPath, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), v.manifest.Sequence)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), v.manifest.Sequence)
+
+	hash, ok := v.remote.File(rolledBackFile)
+	assert.True(t, ok)
+	assert.NotEmpty(t, hash)
+
+	deleted, err := os.ReadFile(filepath.Join(v.gitPath, deletedFile))
+	assert.NoError(t, err)
+
+	err = os.Remove(filepath.Join(path, deletedFile))
+	assert.NoError(t, err)
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), v.manifest.Sequence)
+
+	_, ok = v.remote.File(deletedFile)
+	assert.False(t, ok)
+
+	rolledBack, err := v.crypto.Encrypt([]byte("Lorem ipsum"), crypto.Metadata{}, password, rolledBackFile)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(v.gitPath, rolledBackFile), rolledBack, 0644)
+	assert.NoError(t, err)
+
+	err = os.Remove(filepath.Join(v.gitPath, missingFile))
+	assert.NoError(t, err)
+
+	encrypted, err := v.crypto.Encrypt([]byte("Lorem ipsum"), crypto.Metadata{}, password, addedFile)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(v.gitPath, addedFile), encrypted, 0644)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(v.gitPath, deletedFile), deleted, 0644)
+	assert.NoError(t, err)
+
+	err = v.scan(vaultTypeGit, false)
+	assert.NoError(t, err)
+
+	err = v.loadRemote(password)
+	assert.NoError(t, err)
+
+	untrusted, err := v.checkRemote(v.files)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{rolledBackFile: true, missingFile: true, addedFile: true, deletedFile: true}, untrusted)
+
+	// the push encrypts the untrusted files of the local vault again, and records none of the others
+	err = v.Push(password, false, DeleteLimit{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), v.manifest.Sequence)
+
+	for _, file := range []string{rolledBackFile, missingFile} {
+		data, err := os.ReadFile(filepath.Join(v.gitPath, file))
+		assert.NoError(t, err)
+
+		hash, ok := v.remote.File(file)
+		assert.True(t, ok)
+		assert.Equal(t, manifest.Hash(data), hash)
+	}
+
+	hash, _ = v.remote.File(rolledBackFile)
+	assert.NotEqual(t, manifest.Hash(rolledBack), hash)
+
+	err = v.scan(vaultTypeGit, false)
+	assert.NoError(t, err)
+
+	untrusted, err = v.checkRemote(v.files)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{addedFile: true, deletedFile: true}, untrusted)
+
+	v.manifest.Sequence = 4
+	err = v.manifest.Save(v.getManifestPath())
+	assert.NoError(t, err)
+
+	err = v.Pull(password, false)
+	assert.ErrorContains(t, err, "git vault may have been rolled back")
+
+	err = v.Push(password, false, DeleteLimit{})
+	assert.ErrorContains(t, err, "git vault may have been rolled back")
+}
+
+func TestRemoteManifestMigration(t *testing.T) {
+	path := copyExample(t, "example")
+	fileName := filepath.Join("folder-1", "File-1.md")
+
+	v := newTestVault(t, path, Options{})
+
+	err := v.Push(testPassword, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	encrypted, err := os.ReadFile(filepath.Join(v.gitPath, fileName))
+	assert.NoError(t, err)
+
+	// a git vault pushed before the remote manifest recorded hashes has its unchanged files verified and recorded as they are
+	err = os.Remove(filepath.Join(v.gitPath, remoteManifest))
+	assert.NoError(t, err)
+
+	v.manifest.Sequence = 0
+	err = v.manifest.Save(v.getManifestPath())
+	assert.NoError(t, err)
+
+	err = v.Push(testPassword, false, DeleteLimit{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), v.manifest.Sequence)
+
+	data, err := os.ReadFile(filepath.Join(v.gitPath, fileName))
+	assert.NoError(t, err)
+	assert.Equal(t, encrypted, data)
+
+	hash, ok := v.remote.File(fileName)
+	assert.True(t, ok)
+	assert.Equal(t, manifest.Hash(encrypted), hash)
+
+	_, gitFiles, err := v.list(v.gitPath)
+	assert.NoError(t, err)
+	assert.Len(t, v.remote.Files, len(gitFiles))
+}
+
+func TestPullAfterPrune(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	// the initial commit of the remote is old enough to be dropped
+	err = a.Prune(retention.New(7, 30, 0), false, func(_, _ int) bool { return true })
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	assert.ErrorContains(t, err, "git vault history was pruned on another device")
+
+	err = a.Pull(testPassword, false)
+	assert.NoError(t, err)
+}
+
+func TestPullDryRun(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	editedFile := filepath.Join("folder-1", "File-1.md")
+	deletedFile := filepath.Join("folder-1", "File-2.md")
+	addedFile := filepath.Join("folder-1", "File-4.md")
+
+	err = os.WriteFile(filepath.Join(a.localPath, editedFile), []byte("Edited"), 0644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(a.localPath, addedFile), []byte("Added"), 0644)
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(a.localPath, deletedFile))
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	head, err := b.git.Head(b.stderr)
+	require.NoError(t, err)
+
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	err = b.Pull(testPassword, true)
+	assert.NoError(t, err)
+
+	for _, file := range []string{editedFile, addedFile} {
+		assert.Equal(t, 1, logs.FilterMessage("would decrypt file: "+filepath.Join(b.localPath, file)).Len())
+	}
+	for _, file := range []string{editedFile, deletedFile} {
+		assert.Equal(t, 1, logs.FilterMessage("would move file to trash: "+filepath.Join(b.localPath, file)).Len())
+	}
+	assert.Zero(t, logs.FilterMessage("would move file to trash: "+filepath.Join(b.localPath, addedFile)).Len())
+
+	after, err := b.git.Head(b.stderr)
+	require.NoError(t, err)
+	assert.Equal(t, head, after)
+
+	data, err := os.ReadFile(filepath.Join(b.localPath, editedFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "# Title 1")
+
+	_, err = os.Stat(filepath.Join(b.localPath, deletedFile))
+	assert.NoError(t, err)
+}
+
+func TestResolveRevision(t *testing.T) {
+	remote := newTestRemote(t)
+	v := cloneTestVault(t, remote, copyExample(t, "example"), Options{})
+	note := filepath.Join("folder-1", "File-1.md")
+
+	var commits []string
+	for i, day := range []int{10, 11} {
+		date := time.Date(2026, 3, day, 10, 0, 0, 0, time.Local).Format(time.RFC3339)
+		t.Setenv("GIT_AUTHOR_DATE", date)
+		t.Setenv("GIT_COMMITTER_DATE", date)
+
+		err := os.WriteFile(filepath.Join(v.localPath, note), []byte(fmt.Sprintf("Version %d", i)), 0644)
+		require.NoError(t, err)
+
+		err = v.Push(testPassword, false, DeleteLimit{})
+		require.NoError(t, err)
+
+		head, err := v.git.Head(v.stderr)
+		require.NoError(t, err)
+		commits = append(commits, head)
+	}
+	os.Unsetenv("GIT_AUTHOR_DATE")
+	os.Unsetenv("GIT_COMMITTER_DATE")
+
+	commit, err := v.resolveRevision("2026-03-10")
+	assert.NoError(t, err)
+	assert.NotEqual(t, commits[0], commit)
+
+	commit, err = v.resolveRevision("2026-03-11")
+	assert.NoError(t, err)
+	assert.Equal(t, commits[0], commit)
+
+	commit, err = v.resolveRevision("2026-03-11 09:00:00")
+	assert.NoError(t, err)
+	assert.Equal(t, commits[0], commit)
+
+	_, err = v.resolveRevision("2025-12-01")
+	assert.Error(t, err)
+
+	err = v.CreateSnapshot("first", "2026-03-11")
+	require.NoError(t, err)
+
+	commit, err = v.resolveRevision("first")
+	assert.NoError(t, err)
+	assert.Equal(t, commits[0], commit)
+
+	commit, err = v.resolveRevision("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, commits[1], commit)
+
+	output := t.TempDir()
+	err = v.RestoreAll("first", output, testPassword)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(output, note))
+	assert.NoError(t, err)
+	assert.Equal(t, "Version 0", string(data))
+}
+
+func TestRestoreIntoLocalVault(t *testing.T) {
+	remote := newTestRemote(t)
+	path := copyExample(t, "example")
+	note := filepath.Join("folder-1", "File-1.md")
+	link := "Link.md"
+
+	err := os.Symlink(note, filepath.Join(path, link))
+	require.NoError(t, err)
+
+	v := cloneTestVault(t, remote, path, Options{Symlinks: SymlinkStore})
+
+	err = v.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(path, note), []byte("Lorem ipsum"), 0644)
+	require.NoError(t, err)
+
+	err = os.Remove(filepath.Join(path, link))
+	require.NoError(t, err)
+
+	v, err = New(path, ".obsidian", Options{Symlinks: SymlinkSkip})
+	require.NoError(t, err)
+
+	err = v.Restore(path, "HEAD", "", testPassword)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(path, note))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "# Title 1")
+
+	_, err = os.Lstat(filepath.Join(path, link))
+	assert.True(t, os.IsNotExist(err))
+
+	names, err := v.listTrashes()
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	trashed, err := os.ReadFile(filepath.Join(v.getTrashPath(), names[0], note))
+	assert.NoError(t, err)
+	assert.Equal(t, "Lorem ipsum", string(trashed))
+
+	v, err = New(path, ".obsidian", Options{Symlinks: SymlinkStore})
+	require.NoError(t, err)
+
+	err = v.Restore(filepath.Join(path, link), "HEAD", "", testPassword)
+	assert.NoError(t, err)
+
+	target, err := os.Readlink(filepath.Join(path, link))
+	assert.NoError(t, err)
+	assert.Equal(t, note, target)
+
+	names, err = v.listTrashes()
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+}
+
+func TestPushModTime(t *testing.T) {
+	path := copyExample(t, "example")
+	file := filepath.Join("folder-1", "File-1.md")
+	modTime := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	v := newTestVault(t, path, Options{})
+
+	err := v.Push(testPassword, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	err = os.Chtimes(filepath.Join(path, file), modTime, modTime)
+	assert.NoError(t, err)
+
+	err = v.Push(testPassword, false, DeleteLimit{})
+	assert.NoError(t, err)
+
+	encrypted, err := os.ReadFile(filepath.Join(v.gitPath, file))
+	assert.NoError(t, err)
+
+	_, metadata, err := v.crypto.Decrypt(encrypted, testPassword, file)
+	assert.NoError(t, err)
+	assert.True(t, modTime.Equal(metadata.ModTime))
+}
+
+func TestWriteReadOnlyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "File-1.md")
+
+	err := os.WriteFile(path, []byte("Lorem ipsum"), 0444)
+	assert.NoError(t, err)
+
+	err = writeFile(path, []byte("Dolor sit amet"), crypto.Metadata{Mode: 0666})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "Dolor sit amet", string(data))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Zero(t, info.Mode().Perm()&0022)
+		assert.Zero(t, info.Mode().Perm()&0111)
+	}
+}
+
+func TestMergeTombstones(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	deletedOnA := filepath.Join("folder-1", "File-2.md")
+	deletedOnB := filepath.Join("folder-2", "folder-3", "File-3.md")
+
+	err = os.Remove(filepath.Join(a.localPath, deletedOnA))
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	// both devices change the remote manifest between pulls, so the push of b is rejected
+	err = os.Remove(filepath.Join(b.localPath, deletedOnB))
+	require.NoError(t, err)
+
+	err = b.Push(testPassword, false, DeleteLimit{})
+	assert.Error(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	conflicts, err := b.git.Conflicts(b.stderr)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	_, err = os.Stat(filepath.Join(b.localPath, deletedOnA))
+	assert.True(t, os.IsNotExist(err))
+
+	err = b.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = a.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(a.localPath, deletedOnB))
+	assert.True(t, os.IsNotExist(err))
+
+	for _, v := range []*Vault{a, b} {
+		for _, file := range []string{deletedOnA, deletedOnB} {
+			_, ok := v.remote.Tombstone(file)
+			assert.True(t, ok, file)
+		}
+	}
+
+	// a file changed on both devices is not merged, leaving the git vault as it was
+	err = os.WriteFile(filepath.Join(a.localPath, "Draw.canvas"), []byte("{}"), 0644)
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(b.localPath, "Draw.canvas"), []byte("[]"), 0644)
+	require.NoError(t, err)
+
+	err = b.Push(testPassword, false, DeleteLimit{})
+	assert.Error(t, err)
+
+	err = b.Pull(testPassword, false)
+	assert.ErrorContains(t, err, "files changed on both sides: Draw.canvas")
+
+	conflicts, err = b.git.Conflicts(b.stderr)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
+
+func TestMergeRemoteHashes(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	editedOnA := filepath.Join("folder-1", "File-1.md")
+	editedOnB := filepath.Join("folder-2", "folder-3", "File-3.md")
+
+	err = os.WriteFile(filepath.Join(a.localPath, editedOnA), []byte("Edited on a"), 0644)
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(b.localPath, editedOnB), []byte("Edited on b"), 0644)
+	require.NoError(t, err)
+
+	err = b.Push(testPassword, false, DeleteLimit{})
+	assert.Error(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	err = b.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = a.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	// both devices trust the files edited on either of them
+	for _, v := range []*Vault{a, b} {
+		_, gitFiles, err := v.list(v.gitPath)
+		assert.NoError(t, err)
+
+		untrusted, err := v.checkRemote(gitFiles)
+		assert.NoError(t, err)
+		assert.Empty(t, untrusted)
+
+		for file, content := range map[string]string{editedOnA: "Edited on a", editedOnB: "Edited on b"} {
+			data, err := os.ReadFile(filepath.Join(v.gitPath, file))
+			assert.NoError(t, err)
+
+			hash, ok := v.remote.File(file)
+			assert.True(t, ok, file)
+			assert.Equal(t, manifest.Hash(data), hash, file)
+
+			data, err = os.ReadFile(filepath.Join(v.localPath, file))
+			assert.NoError(t, err)
+			assert.Equal(t, content, string(data))
+		}
+	}
+}
+
+func TestUndoThenPush(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{Backups: 1, EncryptBackups: true})
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	addedFile := filepath.Join("folder-1", "File-4.md")
+	editedFile := filepath.Join("folder-1", "File-1.md")
+	deletedFile := filepath.Join("folder-1", "File-2.md")
+	localFile := filepath.Join("folder-2", "folder-3", "File-3.md")
+
+	err = os.WriteFile(filepath.Join(a.localPath, addedFile), []byte("Added"), 0644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(a.localPath, editedFile), []byte("Edited"), 0644)
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(a.localPath, deletedFile))
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	err = b.Undo(testPassword)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(b.localPath, addedFile))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(b.localPath, deletedFile))
+	assert.NoError(t, err)
+
+	// the push after the undo only pushes the local edit, leaving the changes of the pull in the git vault
+	err = os.WriteFile(filepath.Join(b.localPath, localFile), []byte("Local"), 0644)
+	require.NoError(t, err)
+
+	err = b.Push(testPassword, false, DefaultDeleteLimit)
+	require.NoError(t, err)
+
+	for file, content := range map[string]string{addedFile: "Added", editedFile: "Edited", localFile: "Local"} {
+		encrypted, err := os.ReadFile(filepath.Join(b.gitPath, file))
+		assert.NoError(t, err)
+
+		decrypted, _, err := b.crypto.Decrypt(encrypted, testPassword, file)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(decrypted))
+	}
+
+	_, err = os.Stat(filepath.Join(b.gitPath, deletedFile))
+	assert.True(t, os.IsNotExist(err))
+
+	_, ok := b.remote.Tombstone(addedFile)
+	assert.False(t, ok)
+
+	// the next pull applies the changes of the undone pull again
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	for file, content := range map[string]string{addedFile: "Added", editedFile: "Edited", localFile: "Local"} {
+		data, err := os.ReadFile(filepath.Join(b.localPath, file))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	_, err = os.Stat(filepath.Join(b.localPath, deletedFile))
+	assert.True(t, os.IsNotExist(err))
+
+	err = a.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(a.localPath, localFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "Local", string(data))
+}
+
+func TestParallel(t *testing.T) {
+	v := newTestVault(t, t.TempDir(), Options{})
+
+	var done atomic.Int32
+	err := v.parallel(8, func(i int) error {
+		if i == 0 {
+			return errors.New("failed")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		done.Add(1)
+		return nil
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, int32(7), done.Load())
+}
+
+func TestBackupWithinSecond(t *testing.T) {
+	v := newTestVault(t, copyExample(t, "example"), Options{Backups: 5})
+
+	for range 3 {
+		err := v.backup(testPassword)
+		require.NoError(t, err)
+	}
+
+	names, err := v.listBackups()
+	assert.NoError(t, err)
+	assert.Len(t, names, 3)
+}
+
+func TestRemoteManifestShards(t *testing.T) {
+	remote := newTestRemote(t)
+	a := cloneTestVault(t, remote, copyExample(t, "a"), Options{})
+	b := cloneTestVault(t, remote, filepath.Join(t.TempDir(), "b"), Options{})
+	editedFile := filepath.Join("folder-1", "File-1.md")
+
+	err := a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	files := strings.Split(runGit(t, "", "--git-dir", remote, "ls-tree", "-r", "--name-only", "main"), "\n")
+	for _, file := range []string{".ovmanifest", ".obsidian/.ovmanifest", "folder-1/.ovmanifest", "folder-2/.ovmanifest"} {
+		assert.Contains(t, files, file)
+	}
+
+	// only the root and the shard of the edited directory are rewritten
+	err = os.WriteFile(filepath.Join(a.localPath, editedFile), []byte("Edited"), 0644)
+	require.NoError(t, err)
+
+	err = a.Push(testPassword, false, DeleteLimit{})
+	require.NoError(t, err)
+
+	changed := runGit(t, "", "--git-dir", remote, "diff", "--name-only", "main~1", "main")
+	assert.Equal(t, ".ovmanifest\nfolder-1/.ovmanifest\nfolder-1/File-1.md", changed)
+
+	err = b.Pull(testPassword, false)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(b.localPath, editedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "Edited", string(data))
+
+	_, err = os.Stat(filepath.Join(b.localPath, "folder-1", remoteManifest))
+	assert.True(t, os.IsNotExist(err))
+
+	// a shard rolled back to an older version is refused
+	show := exec.Command("git", "show", "HEAD~1:folder-1/.ovmanifest")
+	show.Dir = b.gitPath
+	previous, err := show.Output()
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(b.gitPath, "folder-1", remoteManifest), previous, 0644)
+	require.NoError(t, err)
+
+	err = b.Push(testPassword, false, DeleteLimit{})
+	assert.ErrorContains(t, err, filepath.Join("folder-1", remoteManifest)+" does not match remote manifest")
+}
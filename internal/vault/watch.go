@@ -0,0 +1,61 @@
+package vault
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jhandguy/obsidian-vault/internal/obsidian"
+	"github.com/jhandguy/obsidian-vault/internal/watcher"
+	"go.uber.org/zap"
+)
+
+func (v *Vault) Watch(ctx context.Context, password string, debounce time.Duration) error {
+	if err := v.Push(password, false, DefaultDeleteLimit); err != nil {
+		return err
+	}
+
+	w, err := watcher.New(v.localPath, v.isWatchNoise)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	zap.S().Infof("👀 watching vault: %s", v.localPath)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			zap.S().Info("✅ vault watch stopped")
+			return nil
+		case err := <-w.Errors:
+			return err
+		case p := <-w.Events:
+			zap.S().Debugf("changed path: %s", p)
+			timer.Reset(debounce)
+		case <-timer.C:
+			if err := v.Push(password, false, DefaultDeleteLimit); err != nil {
+				zap.S().Errorf("❌ %v", err)
+			}
+			zap.S().Infof("👀 watching vault: %s", v.localPath)
+		}
+	}
+}
+
+func (v *Vault) isWatchNoise(relativePath string) bool {
+	if strings.HasPrefix(filepath.Base(relativePath), ".git") {
+		return true
+	}
+
+	info, err := os.Lstat(filepath.Join(v.localPath, relativePath))
+	if v.excluded(relativePath, err == nil && info.IsDir()) {
+		return true
+	}
+
+	configPath, ok := v.getConfigPath(relativePath)
+	return ok && obsidian.Volatile(configPath)
+}
@@ -0,0 +1,139 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const mask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+type Watcher struct {
+	Events  chan string
+	Errors  chan error
+	root    string
+	skip    func(string) bool
+	fd      int
+	file    *os.File
+	watches map[int]string
+}
+
+func New(root string, skip func(string) bool) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		Events:  make(chan string, 1),
+		Errors:  make(chan error, 1),
+		root:    root,
+		skip:    skip,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: map[int]string{},
+	}
+
+	if err := w.add(root); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.read()
+	return w, nil
+}
+
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
+
+func (w *Watcher) add(dir string) error {
+	fn := func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		if p != w.root && w.skipped(p) {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, p, mask)
+		if err != nil {
+			return err
+		}
+
+		w.watches[wd] = p
+		return nil
+	}
+
+	return filepath.WalkDir(dir, fn)
+}
+
+func (w *Watcher) read() {
+	buffer := make([]byte, 4096*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buffer)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		}
+		if err != nil {
+			w.Errors <- err
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				w.notify("")
+				continue
+			}
+
+			dir, ok := w.watches[int(event.Wd)]
+			if !ok {
+				continue
+			}
+
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.watches, int(event.Wd))
+				continue
+			}
+
+			p := filepath.Join(dir, strings.TrimRight(string(buffer[start:offset]), "\x00"))
+			if w.skipped(p) {
+				continue
+			}
+
+			if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if err := w.add(p); err != nil {
+					w.Errors <- err
+					return
+				}
+			}
+
+			w.notify(p)
+		}
+	}
+}
+
+func (w *Watcher) skipped(p string) bool {
+	relativePath, err := filepath.Rel(w.root, p)
+	return err == nil && w.skip(relativePath)
+}
+
+func (w *Watcher) notify(p string) {
+	relativePath, _ := filepath.Rel(w.root, p)
+	select {
+	case w.Events <- relativePath:
+	default:
+	}
+}
@@ -0,0 +1,122 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+const interval = time.Second
+
+type Watcher struct {
+	Events chan string
+	Errors chan error
+	root   string
+	skip   func(string) bool
+	done   chan struct{}
+}
+
+type state struct {
+	size    int64
+	modTime time.Time
+}
+
+func New(root string, skip func(string) bool) (*Watcher, error) {
+	w := &Watcher{
+		Events: make(chan string, 1),
+		Errors: make(chan error, 1),
+		root:   root,
+		skip:   skip,
+		done:   make(chan struct{}),
+	}
+
+	states, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	go w.poll(states)
+	return w, nil
+}
+
+func (w *Watcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+func (w *Watcher) poll(previous map[string]state) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := w.scan()
+		if err != nil {
+			w.Errors <- err
+			return
+		}
+
+		for p, s := range current {
+			if previous[p] != s {
+				w.notify(p)
+			}
+		}
+
+		for p := range previous {
+			if _, ok := current[p]; !ok {
+				w.notify(p)
+			}
+		}
+
+		previous = current
+	}
+}
+
+func (w *Watcher) scan() (map[string]state, error) {
+	states := map[string]state{}
+	fn := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+
+		if p != w.root && w.skip(relativePath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		states[relativePath] = state{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	}
+
+	if err := filepath.WalkDir(w.root, fn); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+func (w *Watcher) notify(relativePath string) {
+	select {
+	case w.Events <- relativePath:
+	default:
+	}
+}
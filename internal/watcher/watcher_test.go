@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherNotifiesChanges(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, ".git-vault"), os.ModePerm)
+	assert.NoError(t, err)
+
+	skip := func(p string) bool {
+		return strings.HasPrefix(p, ".git")
+	}
+
+	w, err := New(root, skip)
+	assert.NoError(t, err)
+	defer w.Close()
+
+	err = os.WriteFile(filepath.Join(root, ".git-vault", "File-1.md"), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(root, "folder-1"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(root, "folder-1", "File-1.md"), []byte("Lorem ipsum"), 0644)
+	assert.NoError(t, err)
+
+	select {
+	case p := <-w.Events:
+		assert.True(t, strings.HasPrefix(p, "folder-1"))
+	case err := <-w.Errors:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "no event received")
+	}
+}